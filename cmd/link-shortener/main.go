@@ -3,15 +3,19 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
-	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"github.com/guregu/dynamo/v2"
+	"github.com/the-redx/link-shortener/internal/config"
 	"github.com/the-redx/link-shortener/internal/handlers"
 	"github.com/the-redx/link-shortener/internal/services"
 	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
 	"golang.org/x/exp/rand"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -20,66 +24,248 @@ import (
 	"github.com/awslabs/aws-lambda-go-api-proxy/gorillamux"
 )
 
-var muxLambda *gorillamux.GorillaMuxAdapter
-
 func init() {
 	rand.Seed(uint64(time.Now().UnixNano()))
-	appEnv := os.Getenv("APP_ENV")
-	if appEnv == "" {
-		os.Setenv("APP_ENV", "development")
-		appEnv = "development"
-	}
+}
 
-	log.Println("App env: ", appEnv)
+// lambdaHandler flushes the unique visitors after every invocation. Lambda freezes
+// the runtime between invocations and reaps it without a signal, so nothing can
+// be left buffered for a later flush.
+func lambdaHandler(muxLambda *gorillamux.GorillaMuxAdapter, visitorCounter *services.VisitorCounter) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		apiGatewayResponse, err := muxLambda.ProxyWithContext(ctx, *core.NewSwitchableAPIGatewayRequestV1(&req))
 
-	if appEnv == "development" {
-		if err := godotenv.Load(".env.development"); err != nil {
-			log.Panicln("Error loading .env.development file")
+		if err := visitorCounter.Flush(ctx); err != nil {
+			utils.Logger.Error("Error while flushing visitors", zap.Error(err))
 		}
-	}
 
-	if err := godotenv.Load(); err != nil {
-		log.Panicln("Error loading .env file")
+		return *apiGatewayResponse.Version1(), err
 	}
 }
 
-func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	apiGatewayResponse, err := muxLambda.ProxyWithContext(ctx, *core.NewSwitchableAPIGatewayRequestV1(&req))
-
-	return *apiGatewayResponse.Version1(), err
-}
-
 func main() {
-	utils.InitLogger()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%s", err)
+	}
+
+	utils.InitLogger(cfg.LogLevel, cfg.Env)
 	defer utils.Logger.Sync()
 
 	utils.Logger.Info("Starting the application...")
 
-	linkService := services.NewLinkService()
-	rateLimiterService := services.NewRateLimiter(60, time.Minute*10)
-	ch := handlers.NewLinkHandler(linkService)
+	var linkRepository services.LinkRepository
+	var clickRepository services.ClickRepository
+	var linkStatsRepository services.LinkStatsRepository
+	var userRepository services.UserRepository
+	var apiKeyRepository services.ApiKeyRepository
+	var workspaceRepository services.WorkspaceRepository
+	var dynamoDB *dynamo.DB
+	if cfg.LinkStorage == "memory" {
+		utils.Logger.Info("Use in-memory link storage")
+		linkRepository = services.NewMemoryLinkRepository()
+		clickRepository = services.NewMemoryClickRepository()
+		linkStatsRepository = services.NewMemoryLinkStatsRepository()
+		userRepository = services.NewMemoryUserRepository()
+		apiKeyRepository = services.NewMemoryApiKeyRepository()
+		workspaceRepository = services.NewMemoryWorkspaceRepository()
+	} else {
+		dynamoDB = services.NewDynamoDBService(cfg.AwsRegion, cfg.DynamoDBEndpoint)
+		linkRepository = services.NewDynamoDBLinkRepository(dynamoDB)
+		clickRepository = services.NewDynamoDBClickRepository(dynamoDB)
+		linkStatsRepository = services.NewDynamoDBLinkStatsRepository(dynamoDB)
+		userRepository = services.NewDynamoDBUserRepository(dynamoDB)
+		apiKeyRepository = services.NewDynamoDBApiKeyRepository(dynamoDB)
+		workspaceRepository = services.NewDynamoDBWorkspaceRepository(dynamoDB)
+	}
+
+	// Lambda can't flush in the background, redirects are counted and clicks written right away there
+	isLambda := cfg.ResponseClient == "lambda"
+
+	var redirectCounter services.RedirectCounter = services.NewDirectRedirectCounter(linkRepository)
+	if cfg.RedirectCounterFlushInterval > 0 && isLambda {
+		utils.Logger.Warn("REDIRECT_COUNTER_FLUSH_INTERVAL is ignored with Lambda. Count redirects directly")
+	} else if cfg.RedirectCounterFlushInterval > 0 {
+		utils.Logger.Infof("Flush redirect counters every %s", cfg.RedirectCounterFlushInterval)
+		redirectCounter = services.NewBufferedRedirectCounter(linkRepository, cfg.RedirectCounterFlushInterval)
+	}
+
+	authorizer := services.NewAuthorizer(workspaceRepository)
+
+	s3Client := services.NewS3Service(cfg.S3)
+	linkService := services.NewLinkService(linkRepository, linkStatsRepository, redirectCounter, authorizer, userRepository, s3Client, cfg.S3, cfg.Attachments, cfg.DomainName)
+
+	attachmentCleaner := services.NewAttachmentCleaner(s3Client, cfg.S3.Bucket, cfg.Attachments.RetentionPrefix)
+	attachmentReconciler := services.NewAttachmentReconciler(linkRepository, s3Client, cfg.S3.Bucket, attachmentCleaner)
+	if cfg.AttachmentReconcileInterval > 0 {
+		// Orphans are only reported unless removing them is enabled
+		utils.Logger.Infof("Reconcile attachments every %s. Remove orphans: %t", cfg.AttachmentReconcileInterval, cfg.AttachmentReconcileRemove)
+		attachmentReconciler.RunEvery(cfg.AttachmentReconcileInterval, cfg.AttachmentReconcileRemove)
+	}
+	workspaceService := services.NewWorkspaceService(workspaceRepository, userRepository, authorizer)
+	clickIPSalt := cfg.ClickIPSalt
+	if clickIPSalt == "" {
+		utils.Logger.Warn("CLICK_IP_SALT is not set. Use a random salt in development, IP hashes will change after restart")
+		clickIPSalt = utils.RandomToken(16)
+	}
+
+	clickRecorderWorkers := 4
+	if isLambda {
+		clickRecorderWorkers = 0
+	}
+
+	clickRecorder := services.NewClickRecorder(clickRepository, 1000, clickRecorderWorkers)
+	visitorCounter := services.NewVisitorCounter(linkStatsRepository, cfg.VisitorFlushInterval)
+	analyticsService := services.NewAnalyticsService(clickRepository, linkStatsRepository, clickRecorder, visitorCounter, clickIPSalt)
+
+	var botRules *services.BotRules
+	if cfg.BotRulesFile != "" {
+		rules, err := services.LoadBotRules(cfg.BotRulesFile)
+		if err != nil {
+			utils.Logger.Fatal(err)
+		}
+
+		botRules = rules
+	}
+
+	botDetector, err := services.NewBotDetector(botRules)
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+
+	var tokenVerifier *services.TokenVerifier
+	if cfg.JWT.Secret != "" || cfg.JWT.JWKSFile != "" {
+		verifier, err := services.NewTokenVerifier(cfg.JWT)
+		if err != nil {
+			utils.Logger.Fatal(err)
+		}
+
+		utils.Logger.Info("Accept JWT bearer tokens")
+		tokenVerifier = verifier
+	}
+
+	authService := services.NewAuthService(userRepository, apiKeyRepository, tokenVerifier)
+
+	if cfg.AdminApiKey == "" {
+		utils.Logger.Warn("ADMIN_API_KEY is not set. Users can't be created")
+	}
+
+	rateLimiterFactory, err := services.NewRateLimiterFactory(cfg.RateLimiter, dynamoDB)
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+
+	rateLimiterService := services.NewKeyedRateLimiter("api", cfg.ApiRateLimit, cfg.RateLimitMaxKeys, rateLimiterFactory)
+	redirectRateLimiter := services.NewKeyedRateLimiter("redirect", cfg.RedirectRateLimit, cfg.RateLimitMaxKeys, rateLimiterFactory)
+	authFailureLimiter := handlers.NewFailedAuthLimiter(services.NewKeyedRateLimiter("auth", cfg.AuthFailureRateLimit, cfg.RateLimitMaxKeys, rateLimiterFactory))
+
+	ch := handlers.NewLinkHandler(linkService, analyticsService, botDetector, cfg.ExpiredLinkUrl)
+	ah := handlers.NewAuthHandler(authService)
+	wh := handlers.NewWorkspaceHandler(workspaceService)
+	adh := handlers.NewAdminHandler(attachmentReconciler)
 
 	router := mux.NewRouter()
 
 	router.Use(handlers.LogMW)
+	router.Use(handlers.ClientIPMW(cfg.TrustedProxies))
+
+	router.HandleFunc("/links", handlers.AuthMW(handlers.RateLimitMW(ch.GetAllLinks, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodGet)
+	router.HandleFunc("/links/{link_id}", handlers.AuthMW(handlers.RateLimitMW(ch.GetLink, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodGet)
+	router.HandleFunc("/links/{link_id}/stats", handlers.AuthMW(handlers.RateLimitMW(ch.GetLinkStats, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodGet)
+	router.HandleFunc("/links", handlers.AuthMW(handlers.RateLimitMW(ch.CreateLink, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/links/{link_id}", handlers.AuthMW(handlers.RateLimitMW(ch.UpdateLink, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPatch)
+	router.HandleFunc("/links/{link_id}/attachFile", handlers.AuthMW(handlers.RateLimitMW(ch.AttachFileToLink, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/links/{link_id}/attachments", handlers.AuthMW(handlers.RateLimitMW(ch.AddAttachment, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/links/{link_id}/attachments/{attachment_id}", handlers.AuthMW(handlers.RateLimitMW(ch.DeleteAttachment, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodDelete)
+	router.HandleFunc("/links/{link_id}/attachments/uploads", handlers.AuthMW(handlers.RateLimitMW(ch.CreateAttachmentUpload, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/links/{link_id}/attachments/uploads/{upload_id}/complete", handlers.AuthMW(handlers.RateLimitMW(ch.CompleteAttachmentUpload, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/links/{link_id}", handlers.AuthMW(handlers.RateLimitMW(ch.DeleteLink, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodDelete)
+	router.HandleFunc("/admin/attachments/reconcile", handlers.AdminMW(handlers.RateLimitMW(adh.ReconcileAttachments, rateLimiterService), cfg.AdminApiKey, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/users", handlers.AdminMW(handlers.RateLimitMW(ah.CreateUser, rateLimiterService), cfg.AdminApiKey, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/api-keys", handlers.AuthMW(handlers.RateLimitMW(ah.GetApiKeys, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodGet)
+	router.HandleFunc("/api-keys", handlers.AuthMW(handlers.RateLimitMW(ah.CreateApiKey, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/api-keys/{key_id}", handlers.AuthMW(handlers.RateLimitMW(ah.RevokeApiKey, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodDelete)
+	router.HandleFunc("/workspaces", handlers.AuthMW(handlers.RateLimitMW(wh.GetWorkspaces, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodGet)
+	router.HandleFunc("/workspaces", handlers.AuthMW(handlers.RateLimitMW(wh.CreateWorkspace, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPost)
+	router.HandleFunc("/workspaces/{workspace_id}/members", handlers.AuthMW(handlers.RateLimitMW(wh.GetWorkspaceMembers, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodGet)
+	router.HandleFunc("/workspaces/{workspace_id}/members/{user_id}", handlers.AuthMW(handlers.RateLimitMW(wh.PutWorkspaceMember, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodPut)
+	router.HandleFunc("/workspaces/{workspace_id}/members/{user_id}", handlers.AuthMW(handlers.RateLimitMW(wh.DeleteWorkspaceMember, rateLimiterService), authService, authFailureLimiter)).Methods(http.MethodDelete)
+	router.HandleFunc("/{link_id}/files.zip", handlers.RateLimitMW(ch.DownloadLinkBundle, redirectRateLimiter)).Methods(http.MethodGet, http.MethodHead)
+	router.HandleFunc("/{link_id}", handlers.RateLimitMW(ch.RedirectToLink, redirectRateLimiter)).Methods(http.MethodGet, http.MethodHead, http.MethodPost)
 
-	router.HandleFunc("/links", handlers.AuthMW(handlers.RateLimitMW(ch.GetAllLinks, rateLimiterService))).Methods(http.MethodGet)
-	router.HandleFunc("/links/{link_id}", handlers.AuthMW(handlers.RateLimitMW(ch.GetLink, rateLimiterService))).Methods(http.MethodGet)
-	router.HandleFunc("/links", handlers.AuthMW(handlers.RateLimitMW(ch.CreateLink, rateLimiterService))).Methods(http.MethodPost)
-	router.HandleFunc("/links/{link_id}", handlers.AuthMW(handlers.RateLimitMW(ch.UpdateLink, rateLimiterService))).Methods(http.MethodPatch)
-	router.HandleFunc("/links/{link_id}/attachFile", handlers.AuthMW(handlers.RateLimitMW(ch.AttachFileToLink, rateLimiterService))).Methods(http.MethodPost)
-	router.HandleFunc("/links/{link_id}", handlers.AuthMW(handlers.RateLimitMW(ch.DeleteLink, rateLimiterService))).Methods(http.MethodDelete)
-	router.HandleFunc("/{link_id}", handlers.RateLimitMW(ch.RedirectToLink, rateLimiterService)).Methods(http.MethodGet)
-
-	responseClient := os.Getenv("RESPONSE_CLIENT")
-	if responseClient == "mux" {
-		utils.Logger.Info("Use mux as response client")
-		utils.Logger.Fatal(http.ListenAndServe(":4000", router))
-	} else if responseClient == "lambda" {
+	if isLambda {
 		utils.Logger.Info("Use Lambda as response client")
-		muxLambda = gorillamux.New(router)
-		lambda.Start(Handler)
-	} else {
-		utils.Logger.Fatal("Please provide a valid response client")
+		lambda.Start(lambdaHandler(gorillamux.New(router), visitorCounter))
+		return
+	}
+
+	utils.Logger.Info("Use mux as response client")
+	server := &http.Server{
+		Addr:              cfg.Server.Address,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
 	}
+
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	server.BaseContext = func(net.Listener) context.Context { return baseCtx }
+
+	serve(server)
+
+	// One deadline covers the whole shutdown. The connections get three quarters
+	// of it, the rest is left for flushing the workers.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	drainCtx, cancelDrain := context.WithTimeout(ctx, cfg.Server.ShutdownTimeout*3/4)
+	defer cancelDrain()
+
+	if err := server.Shutdown(drainCtx); err != nil {
+		utils.Logger.Error("Error while draining connections. Cancelling the requests left", zap.Error(err))
+		cancelRequests()
+		server.Close()
+	}
+
+	// Clicks are written before the visitor counter is closed, as recording them
+	// adds visitors. Requests still running can't record anymore after this.
+	attachmentReconciler.Close()
+
+	if err := clickRecorder.Close(ctx); err != nil {
+		utils.Logger.Error("Error while writing queued clicks", zap.Error(err))
+	}
+
+	if err := visitorCounter.Close(ctx); err != nil {
+		utils.Logger.Error("Error while flushing visitors", zap.Error(err))
+	}
+
+	if err := redirectCounter.Close(ctx); err != nil {
+		utils.Logger.Error("Error while flushing redirect counters", zap.Error(err))
+	}
+
+	utils.Logger.Info("Stopped")
+}
+
+// serve runs the server until SIGTERM or SIGINT, then returns so it can be shut down.
+func serve(server *http.Server) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		utils.Logger.Infof("Listening on %s", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		utils.Logger.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	// A second signal kills the process right away
+	stop()
+	utils.Logger.Info("Shutting down. Draining connections")
 }
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/services"
+)
+
+// Config holds every setting of the service. Settings are named like the
+// environment variables they're read from.
+type Config struct {
+	Env            string
+	LogLevel       string
+	ResponseClient string
+	// DomainName prefixes the short URLs, e.g. https://example.com
+	DomainName     string
+	ExpiredLinkUrl string
+	AdminApiKey    string
+	TrustedProxies []*net.IPNet
+
+	// LinkStorage is dynamodb or memory, memory is meant for tests and local runs
+	LinkStorage      string
+	AwsRegion        string
+	DynamoDBEndpoint string
+
+	// Redirects are counted straight in the link table while it's zero
+	RedirectCounterFlushInterval time.Duration
+	VisitorFlushInterval         time.Duration
+	ClickIPSalt                  string
+	BotRulesFile                 string
+
+	S3                          services.S3Options
+	Attachments                 services.AttachmentOptions
+	AttachmentReconcileInterval time.Duration
+	AttachmentReconcileRemove   bool
+
+	// JWT bearer tokens are accepted only with a secret or a JWKS file
+	JWT services.TokenVerifierOptions
+
+	RateLimiter       services.RateLimiterOptions
+	ApiRateLimit      services.RateLimitPolicy
+	RedirectRateLimit services.RateLimitPolicy
+	// Failed authentications are limited by client IP
+	AuthFailureRateLimit services.RateLimitPolicy
+	RateLimitMaxKeys     int
+
+	Server ServerConfig
+}
+
+// ServerConfig is the HTTP server of the mux response client. ShutdownTimeout
+// limits both draining the connections and flushing the background workers.
+type ServerConfig struct {
+	Address           string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	ShutdownTimeout   time.Duration
+}
+
+// Load reads the configuration once at startup. Variables of the process win
+// over .env.<APP_ENV>, then .env, then the YAML or TOML file named by CONFIG_FILE.
+// All invalid settings are returned in one error.
+func Load() (*Config, error) {
+	appEnv := os.Getenv("APP_ENV")
+	if appEnv == "" {
+		appEnv = "development"
+	}
+
+	sources := []map[string]string{environ()}
+	for _, name := range []string{".env." + appEnv, ".env"} {
+		values, err := readEnvFile(name)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, values)
+	}
+
+	var fileValues map[string]string
+	if configFile := lookupFirst(sources, "CONFIG_FILE"); configFile != "" {
+		values, err := readConfigFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+
+		fileValues = values
+		sources = append(sources, values)
+	}
+
+	values := make(map[string]string)
+	for i := len(sources) - 1; i >= 0; i-- {
+		for name, value := range sources[i] {
+			values[name] = value
+		}
+	}
+
+	values["APP_ENV"] = appEnv
+	l := newLoader(values)
+	config := load(l)
+
+	// The file holds nothing but settings, so unknown names there are most likely typos
+	var unknown []string
+	for name := range fileValues {
+		if !l.used[name] && name != "CONFIG_FILE" {
+			unknown = append(unknown, name)
+		}
+	}
+
+	slices.Sort(unknown)
+	for _, name := range unknown {
+		l.errs = append(l.errs, fmt.Errorf("%s: unknown setting", name))
+	}
+
+	if len(l.errs) > 0 {
+		return nil, errors.Join(l.errs...)
+	}
+
+	return config, nil
+}
+
+func load(l *loader) *Config {
+	config := Config{
+		Env:            l.string("APP_ENV", "development"),
+		LogLevel:       l.string("APP_LOG_LEVEL", ""),
+		ResponseClient: l.oneOf("RESPONSE_CLIENT", "", "mux", "lambda"),
+		DomainName:     l.url("DOMAIN_NAME"),
+		ExpiredLinkUrl: l.url("EXPIRED_LINK_URL"),
+		AdminApiKey:    l.string("ADMIN_API_KEY", ""),
+		TrustedProxies: parse(l, "TRUSTED_PROXIES", "", parseTrustedProxies),
+
+		LinkStorage:      l.oneOf("LINK_STORAGE", "dynamodb", "dynamodb", "memory"),
+		AwsRegion:        l.string("AWS_REGION", "eu-north-1"),
+		DynamoDBEndpoint: l.url("DYNAMODB_ENDPOINT"),
+
+		RedirectCounterFlushInterval: l.interval("REDIRECT_COUNTER_FLUSH_INTERVAL"),
+		VisitorFlushInterval:         l.duration("VISITOR_FLUSH_INTERVAL", time.Second*10),
+		ClickIPSalt:                  l.string("CLICK_IP_SALT", ""),
+		BotRulesFile:                 l.string("BOT_RULES_FILE", ""),
+
+		AttachmentReconcileInterval: l.interval("ATTACHMENT_RECONCILE_INTERVAL"),
+		AttachmentReconcileRemove:   l.bool("ATTACHMENT_RECONCILE_REMOVE", false),
+
+		JWT: services.TokenVerifierOptions{
+			Secret:    l.string("JWT_SECRET", ""),
+			JWKSFile:  l.string("JWT_JWKS_FILE", ""),
+			Audience:  l.string("JWT_AUDIENCE", ""),
+			Issuer:    l.string("JWT_ISSUER", ""),
+			UserClaim: l.string("JWT_USER_CLAIM", ""),
+		},
+
+		RateLimiter: services.RateLimiterOptions{
+			Backend:   services.RateLimitBackend(l.string("RATE_LIMIT_BACKEND", "")),
+			Algorithm: services.RateLimitAlgorithm(l.string("RATE_LIMIT_ALGORITHM", "")),
+			Address:   l.string("RATE_LIMIT_ADDRESS", ""),
+			Prefix:    l.string("RATE_LIMIT_PREFIX", ""),
+		},
+		ApiRateLimit:         parse(l, "RATE_LIMIT_API", "60/1m", services.ParseRateLimitPolicy),
+		RedirectRateLimit:    parse(l, "RATE_LIMIT_REDIRECT", "120/1m", services.ParseRateLimitPolicy),
+		AuthFailureRateLimit: parse(l, "RATE_LIMIT_AUTH_FAILURES", "10/1m", services.ParseRateLimitPolicy),
+		RateLimitMaxKeys:     l.positiveInt("RATE_LIMIT_MAX_KEYS", 10000),
+	}
+
+	if err := config.RateLimiter.Validate(); err != nil {
+		l.invalid("RATE_LIMIT_BACKEND", string(config.RateLimiter.Backend), err.Error())
+	}
+
+	// A random salt changes the IP hashes on every start and differs between instances,
+	// so the unique visitors are only approximated that way in development
+	if config.ClickIPSalt == "" && config.Env != "development" {
+		l.missing("CLICK_IP_SALT")
+	}
+
+	config.S3 = loadS3(l, config.AwsRegion)
+	config.Attachments = loadAttachments(l)
+	config.Server = loadServer(l)
+
+	return &config
+}
+
+// The bucket may live in another region than the tables
+func loadS3(l *loader, awsRegion string) services.S3Options {
+	options := services.S3Options{
+		Bucket:    l.string("S3_BUCKET", "links-attachments"),
+		Region:    l.string("S3_REGION", awsRegion),
+		Endpoint:  l.url("S3_ENDPOINT"),
+		PublicUrl: l.url("S3_PUBLIC_URL"),
+	}
+
+	// Custom endpoints like LocalStack or MinIO mostly don't resolve bucket subdomains
+	options.UsePathStyle = l.bool("S3_USE_PATH_STYLE", options.Endpoint != "")
+
+	return options
+}
+
+// The signing key has no random fallback, signed ZIP URLs must stay valid across instances and deploys
+func loadAttachments(l *loader) services.AttachmentOptions {
+	options := services.AttachmentOptions{
+		MaxSize:            l.size("ATTACHMENT_MAX_SIZE", 200<<20),
+		PlanMaxSizes:       map[string]int64{},
+		ContentTypes:       l.list("ATTACHMENT_CONTENT_TYPES"),
+		DeniedContentTypes: l.list("ATTACHMENT_DENIED_CONTENT_TYPES"),
+		RetentionPrefix:    l.string("ATTACHMENT_RETENTION_PREFIX", ""),
+		SigningKey:         l.required("ATTACHMENT_SIGNING_KEY"),
+	}
+
+	// The reconciler lists everything under links/, retained objects must stay out of it
+	if strings.HasPrefix(options.RetentionPrefix, "links/") {
+		l.invalid("ATTACHMENT_RETENTION_PREFIX", options.RetentionPrefix, "it can't be under links/")
+	}
+
+	// Written as plan=size pairs, e.g. "free=10485760,pro=1073741824"
+	for _, planSize := range l.list("ATTACHMENT_PLAN_MAX_SIZES") {
+		plan, size, ok := strings.Cut(planSize, "=")
+		if !ok {
+			l.invalid("ATTACHMENT_PLAN_MAX_SIZES", planSize, "expected plan=size")
+			continue
+		}
+
+		options.PlanMaxSizes[strings.TrimSpace(plan)] = l.parseSize("ATTACHMENT_PLAN_MAX_SIZES", strings.TrimSpace(size), 0)
+	}
+
+	return options
+}
+
+func loadServer(l *loader) ServerConfig {
+	return ServerConfig{
+		Address:           parse(l, "HTTP_ADDRESS", ":4000", parseAddress),
+		ReadTimeout:       l.duration("HTTP_READ_TIMEOUT", time.Minute),
+		ReadHeaderTimeout: l.duration("HTTP_READ_HEADER_TIMEOUT", time.Second*10),
+		WriteTimeout:      l.duration("HTTP_WRITE_TIMEOUT", time.Minute),
+		IdleTimeout:       l.duration("HTTP_IDLE_TIMEOUT", time.Minute*2),
+		MaxHeaderBytes:    l.positiveInt("HTTP_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:   l.duration("HTTP_SHUTDOWN_TIMEOUT", time.Second*20),
+	}
+}
+
+func parseAddress(value string) (string, error) {
+	_, _, err := net.SplitHostPort(value)
+	return value, err
+}
+
+// parseTrustedProxies reads a comma separated list of IPs and CIDR ranges.
+func parseTrustedProxies(value string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", item, err)
+		}
+
+		proxies = append(proxies, network)
+	}
+
+	return proxies, nil
+}
+
+func lookupFirst(sources []map[string]string, name string) string {
+	for _, values := range sources {
+		if value := values[name]; value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "10.0.0.0/8", want: []string{"10.0.0.0/8"}},
+		{value: "10.0.0.1", want: []string{"10.0.0.1/32"}},
+		{value: "::1", want: []string{"::1/128"}},
+		{value: " 10.0.0.1 , 192.168.0.0/16,, ", want: []string{"10.0.0.1/32", "192.168.0.0/16"}},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "proxy", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTrustedProxies(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrustedProxies(%q) error = %v, wantErr %t", tt.value, err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseTrustedProxies(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+
+			for i := range got {
+				if got[i].String() != tt.want[i] {
+					t.Errorf("parseTrustedProxies(%q)[%d] = %s, want %s", tt.value, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	valid := map[string]string{
+		"RESPONSE_CLIENT":        "mux",
+		"ATTACHMENT_SIGNING_KEY": "key",
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]string
+		wantErrs []string
+	}{
+		{name: "defaults", values: valid},
+		{
+			name:     "missing required settings",
+			values:   map[string]string{"APP_ENV": "production", "RESPONSE_CLIENT": "mux"},
+			wantErrs: []string{"CLICK_IP_SALT: required", "ATTACHMENT_SIGNING_KEY: required"},
+		},
+		{
+			name: "every invalid setting is reported",
+			values: map[string]string{
+				"RESPONSE_CLIENT":        "grpc",
+				"ATTACHMENT_SIGNING_KEY": "key",
+				"DOMAIN_NAME":            "example.com",
+				"RATE_LIMIT_API":         "fast",
+				"HTTP_ADDRESS":           "4000",
+			},
+			wantErrs: []string{"RESPONSE_CLIENT:", "DOMAIN_NAME:", "RATE_LIMIT_API:", "HTTP_ADDRESS:"},
+		},
+		{
+			name:     "retention prefix under links",
+			values:   map[string]string{"RESPONSE_CLIENT": "mux", "ATTACHMENT_SIGNING_KEY": "key", "ATTACHMENT_RETENTION_PREFIX": "links/old/"},
+			wantErrs: []string{"ATTACHMENT_RETENTION_PREFIX:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLoader(tt.values)
+			config := load(l)
+
+			if len(l.errs) != len(tt.wantErrs) {
+				t.Fatalf("load() errors = %v, want %d errors", l.errs, len(tt.wantErrs))
+			}
+
+			for i, want := range tt.wantErrs {
+				if !strings.HasPrefix(l.errs[i].Error(), want) {
+					t.Errorf("load() error %d = %q, want prefix %q", i, l.errs[i], want)
+				}
+			}
+
+			if len(tt.wantErrs) == 0 && config.Server.ShutdownTimeout != 20*time.Second {
+				t.Errorf("load() ShutdownTimeout = %s, want the default 20s", config.Server.ShutdownTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loader reads typed settings from the merged sources. Invalid values are
+// collected instead of failing on the first one, so they're all reported together.
+type loader struct {
+	values map[string]string
+	used   map[string]bool
+	errs   []error
+}
+
+func newLoader(values map[string]string) *loader {
+	return &loader{values: values, used: make(map[string]bool)}
+}
+
+func (l *loader) lookup(name string) (string, bool) {
+	l.used[name] = true
+	value := strings.TrimSpace(l.values[name])
+
+	return value, value != ""
+}
+
+func (l *loader) invalid(name string, value string, reason string) {
+	l.errs = append(l.errs, fmt.Errorf("%s: invalid value %q, %s", name, value, reason))
+}
+
+func (l *loader) string(name string, fallback string) string {
+	if value, ok := l.lookup(name); ok {
+		return value
+	}
+
+	return fallback
+}
+
+// required reads a setting without a sensible default, a missing one is reported
+func (l *loader) required(name string) string {
+	value, ok := l.lookup(name)
+	if !ok {
+		l.missing(name)
+	}
+
+	return value
+}
+
+func (l *loader) missing(name string) {
+	l.errs = append(l.errs, fmt.Errorf("%s: required setting is missing", name))
+}
+
+func (l *loader) oneOf(name string, fallback string, allowed ...string) string {
+	value := l.string(name, fallback)
+	if !slices.Contains(allowed, value) {
+		l.invalid(name, value, "expected one of "+strings.Join(allowed, ", "))
+	}
+
+	return value
+}
+
+func (l *loader) bool(name string, fallback bool) bool {
+	value, ok := l.lookup(name)
+	if !ok {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.invalid(name, value, "expected true or false")
+		return fallback
+	}
+
+	return parsed
+}
+
+func (l *loader) positiveInt(name string, fallback int) int {
+	value, ok := l.lookup(name)
+	if !ok {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		l.invalid(name, value, "expected a positive number")
+		return fallback
+	}
+
+	return parsed
+}
+
+// size reads a size in bytes
+func (l *loader) size(name string, fallback int64) int64 {
+	value, ok := l.lookup(name)
+	if !ok {
+		return fallback
+	}
+
+	return l.parseSize(name, value, fallback)
+}
+
+func (l *loader) parseSize(name string, value string, fallback int64) int64 {
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed <= 0 {
+		l.invalid(name, value, "expected a size in bytes")
+		return fallback
+	}
+
+	return parsed
+}
+
+func (l *loader) duration(name string, fallback time.Duration) time.Duration {
+	value, ok := l.lookup(name)
+	if !ok {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		l.invalid(name, value, "expected a positive duration like 30s")
+		return fallback
+	}
+
+	return parsed
+}
+
+// interval reads the period of a background job, 0 or no value turns the job off
+func (l *loader) interval(name string) time.Duration {
+	value, ok := l.lookup(name)
+	if !ok {
+		return 0
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		l.invalid(name, value, "expected 0 or a positive duration like 30s")
+		return 0
+	}
+
+	return parsed
+}
+
+func (l *loader) list(name string) []string {
+	value, _ := l.lookup(name)
+
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
+
+// url reads an absolute HTTP(S) URL
+func (l *loader) url(name string) string {
+	value, ok := l.lookup(name)
+	if !ok {
+		return ""
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		l.invalid(name, value, "expected an http or https URL")
+	}
+
+	return value
+}
+
+// parse reads a value with a parser of its own, a failed parse is reported with the error of the parser.
+func parse[T any](l *loader, name string, fallback string, parser func(string) (T, error)) T {
+	value := l.string(name, fallback)
+
+	parsed, err := parser(value)
+	if err != nil {
+		l.invalid(name, value, err.Error())
+	}
+
+	return parsed
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLoaderDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: time.Minute},
+		{value: "30s", want: 30 * time.Second},
+		{value: " 2h ", want: 2 * time.Hour},
+		{value: "0", want: time.Minute, wantErr: true},
+		{value: "-1s", want: time.Minute, wantErr: true},
+		{value: "30", want: time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			l := newLoader(map[string]string{"TIMEOUT": tt.value})
+
+			if got := l.duration("TIMEOUT", time.Minute); got != tt.want {
+				t.Errorf("duration(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+
+			if (len(l.errs) > 0) != tt.wantErr {
+				t.Errorf("duration(%q) errors = %v, wantErr %t", tt.value, l.errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderInterval(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "0", want: 0},
+		{value: "0s", want: 0},
+		{value: "5m", want: 5 * time.Minute},
+		{value: "-5m", want: 0, wantErr: true},
+		{value: "often", want: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			l := newLoader(map[string]string{"INTERVAL": tt.value})
+
+			if got := l.interval("INTERVAL"); got != tt.want {
+				t.Errorf("interval(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+
+			if (len(l.errs) > 0) != tt.wantErr {
+				t.Errorf("interval(%q) errors = %v, wantErr %t", tt.value, l.errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "", want: 100},
+		{value: "1048576", want: 1048576},
+		{value: "0", want: 100, wantErr: true},
+		{value: "10MB", want: 100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			l := newLoader(map[string]string{"SIZE": tt.value})
+
+			if got := l.size("SIZE", 100); got != tt.want {
+				t.Errorf("size(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+
+			if (len(l.errs) > 0) != tt.wantErr {
+				t.Errorf("size(%q) errors = %v, wantErr %t", tt.value, l.errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{value: "", want: true},
+		{value: "false", want: false},
+		{value: "1", want: true},
+		{value: "yes", want: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			l := newLoader(map[string]string{"FLAG": tt.value})
+
+			if got := l.bool("FLAG", true); got != tt.want {
+				t.Errorf("bool(%q) = %t, want %t", tt.value, got, tt.want)
+			}
+
+			if (len(l.errs) > 0) != tt.wantErr {
+				t.Errorf("bool(%q) errors = %v, wantErr %t", tt.value, l.errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderUrl(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "https://example.com"},
+		{value: "http://localhost:4000/path"},
+		{value: "example.com", wantErr: true},
+		{value: "ftp://example.com", wantErr: true},
+		{value: "https://", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			l := newLoader(map[string]string{"URL": tt.value})
+			l.url("URL")
+
+			if (len(l.errs) > 0) != tt.wantErr {
+				t.Errorf("url(%q) errors = %v, wantErr %t", tt.value, l.errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoaderList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{value: "", want: nil},
+		{value: "image/png", want: []string{"image/png"}},
+		{value: " image/png, ,text/plain ,", want: []string{"image/png", "text/plain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			l := newLoader(map[string]string{"LIST": tt.value})
+
+			if got := l.list("LIST"); !slices.Equal(got, tt.want) {
+				t.Errorf("list(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoaderOneOfAndRequired(t *testing.T) {
+	l := newLoader(map[string]string{"STORAGE": "disk"})
+
+	if got := l.oneOf("STORAGE", "memory", "memory", "dynamodb"); got != "disk" {
+		t.Errorf("oneOf() = %q, want the value as it was set", got)
+	}
+
+	if got := l.required("KEY"); got != "" {
+		t.Errorf("required() = %q, want an empty value", got)
+	}
+
+	if len(l.errs) != 2 {
+		t.Fatalf("errors = %v, want 2", l.errs)
+	}
+
+	if !l.used["STORAGE"] || !l.used["KEY"] {
+		t.Errorf("used = %v, want STORAGE and KEY marked", l.used)
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// environ reads the variables of the process.
+func environ() map[string]string {
+	values := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		values[name] = value
+	}
+
+	return values
+}
+
+// readEnvFile reads a .env file. Containers mostly get real variables, so a missing file is fine.
+func readEnvFile(name string) (map[string]string, error) {
+	values, err := godotenv.Read(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return values, nil
+}
+
+// readConfigFile reads a YAML or TOML file into the same names the variables have.
+// Nested tables are joined with underscores, so s3.bucket is S3_BUCKET, and lists
+// are joined with commas.
+func readConfigFile(name string) (map[string]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return nil, fmt.Errorf("%s: unknown format, expected .yaml, .yml or .toml", name)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", document, values); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return values, nil
+}
+
+func flatten(prefix string, value interface{}, values map[string]string) error {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if err := flatten(settingName(prefix, key), item, values); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return fmt.Errorf("%s: lists can only hold plain values", prefix)
+			}
+
+			items = append(items, fmt.Sprint(item))
+		}
+
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		values[prefix] = ""
+	default:
+		values[prefix] = fmt.Sprint(value)
+	}
+
+	return nil
+}
+
+func settingName(prefix string, key string) string {
+	name := strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + "_" + name
+}
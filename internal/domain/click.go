@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"time"
+)
+
+type Click struct {
+	LinkId    string    `json:"linkId" dynamo:"LinkId,hash"`
+	ClickId   string    `json:"id" dynamo:"ClickId,range"`
+	Timestamp time.Time `json:"timestamp" dynamo:"Timestamp,unixtime"`
+	Referrer  string    `json:"referrer" dynamo:"Referrer"`
+	Browser   string    `json:"browser" dynamo:"Browser"`
+	Device    string    `json:"device" dynamo:"Device"`
+	Country   string    `json:"country" dynamo:"Country"`
+	IPHash    string    `json:"ipHash" dynamo:"IPHash"`
+	TraceId   string    `json:"traceId" dynamo:"TraceId"`
+}
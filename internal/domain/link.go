@@ -7,29 +7,144 @@ import (
 type LinkStatus string
 
 const (
-	Active LinkStatus = "active"
-	Paused LinkStatus = "paused"
+	Active  LinkStatus = "active"
+	Paused  LinkStatus = "paused"
+	Expired LinkStatus = "expired"
 )
 
+// Links redirect to their Url, to their latest attachment in the file mode,
+// or show a download page of all attachments in the bundle mode.
+type LinkMode string
+
+const (
+	RedirectMode LinkMode = "redirect"
+	FileMode     LinkMode = "file"
+	BundleMode   LinkMode = "bundle"
+)
+
+type LinkSort string
+
+const (
+	SortByCreated   LinkSort = "created"
+	SortByUpdated   LinkSort = "updated"
+	SortByRedirects LinkSort = "redirects"
+)
+
+// UniqueVisitors is estimated from the link stats and isn't stored on the link.
+// BotRedirects counts crawlers and link preview fetchers, they aren't part of Redirects.
+// A link expires after ExpiresAt or MaxRedirects redirects, then it redirects to ExpiredUrl.
+// PasswordHash is never returned, Protected tells whether the link has a password.
+// Links of a workspace have a WorkspaceId, UserId is then the user who created them.
+// Attachments are the uploaded files of the link, oldest first.
 type Link struct {
-	ID          string     `json:"id" dynamo:"ID,hash"`
-	Name        string     `json:"name" dynamo:"Name"`
-	UserId      string     `json:"-" dynamo:"UserId"`
-	ShortUrl    string     `json:"shortUrl" dynamo:"-"`
-	Redirects   int        `json:"redirects" dynamo:"Redirects"`
-	Url         string     `json:"url" dynamo:"Url"`
-	Status      LinkStatus `json:"status" dynamo:"Status"`
-	DateCreated time.Time  `json:"dateCreated" dynamo:"DateCreated,unixtime"`
-	DateUpdated time.Time  `json:"dateUpdated" dynamo:"DateUpdated,unixtime"`
+	ID             string       `json:"id" dynamo:"ID,hash"`
+	Name           string       `json:"name" dynamo:"Name"`
+	UserId         string       `json:"-" dynamo:"UserId"`
+	WorkspaceId    string       `json:"workspaceId" dynamo:"WorkspaceId,omitempty"`
+	ShortUrl       string       `json:"shortUrl" dynamo:"-"`
+	Redirects      int          `json:"redirects" dynamo:"Redirects"`
+	BotRedirects   int          `json:"botRedirects" dynamo:"BotRedirects"`
+	UniqueVisitors uint64       `json:"uniqueVisitors" dynamo:"-"`
+	Url            string       `json:"url" dynamo:"Url"`
+	Status         LinkStatus   `json:"status" dynamo:"Status"`
+	ExpiresAt      *time.Time   `json:"expiresAt" dynamo:"ExpiresAt,unixtime"`
+	MaxRedirects   int          `json:"maxRedirects" dynamo:"MaxRedirects,omitempty"`
+	ExpiredUrl     string       `json:"expiredUrl" dynamo:"ExpiredUrl,omitempty"`
+	PasswordHash   string       `json:"-" dynamo:"PasswordHash,omitempty"`
+	Protected      bool         `json:"protected" dynamo:"-"`
+	Mode           LinkMode     `json:"mode" dynamo:"Mode,omitempty"`
+	Attachments    []Attachment `json:"attachments" dynamo:"Attachments,omitempty"`
+	DateCreated    time.Time    `json:"dateCreated" dynamo:"DateCreated,unixtime"`
+	DateUpdated    time.Time    `json:"dateUpdated" dynamo:"DateUpdated,unixtime"`
+}
+
+// Attachment is a file uploaded to S3 under Key. Checksum is the hex SHA-256 of
+// the file, it's empty when a browser uploaded the file without an S3 checksum.
+type Attachment struct {
+	ID           string    `json:"id" dynamo:"ID"`
+	Key          string    `json:"-" dynamo:"Key"`
+	FileName     string    `json:"fileName" dynamo:"FileName"`
+	Size         int64     `json:"size" dynamo:"Size"`
+	ContentType  string    `json:"contentType" dynamo:"ContentType"`
+	Checksum     string    `json:"checksum" dynamo:"Checksum"`
+	DateUploaded time.Time `json:"dateUploaded" dynamo:"DateUploaded,unixtime"`
+}
+
+// CreateAttachmentUploadDTO describes a file the browser is going to upload straight to S3.
+type CreateAttachmentUploadDTO struct {
+	FileName    string `json:"fileName" validate:"required,max=255"`
+	ContentType string `json:"contentType" validate:"required,max=255"`
+	Size        int64  `json:"size" validate:"required,min=1"`
+}
+
+// AttachmentUpload is a presigned POST form. The browser posts Fields and then
+// the file to Url, and completes the upload with its ID afterwards.
+type AttachmentUpload struct {
+	ID        string            `json:"id"`
+	Url       string            `json:"url"`
+	Fields    map[string]string `json:"fields"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// AttachmentReconcileReport lists the objects of one page of the attachments bucket
+// that no link uses. Removed is false when they were only reported. NextStartAfter
+// is the key to continue after, nil on the last page.
+type AttachmentReconcileReport struct {
+	Checked        int      `json:"checked"`
+	Orphaned       []string `json:"orphaned"`
+	Removed        bool     `json:"removed"`
+	Failed         []string `json:"failed"`
+	NextStartAfter *string  `json:"nextStartAfter"`
+}
+
+// LinkBundle is what the download page of a bundle link shows. Its URLs expire after a while.
+type LinkBundle struct {
+	Name   string
+	Files  []BundleFile
+	ZipUrl string
+}
+
+type BundleFile struct {
+	Name string
+	Size int64
+	Url  string
 }
 
 type CreateLinkDTO struct {
-	ID   string `json:"id" validate:"max=30"`
-	Name string `json:"name" validate:"max=100"`
-	Url  string `json:"url" validate:"required,url,max=5000"`
+	ID           string     `json:"id" validate:"max=30"`
+	WorkspaceId  string     `json:"workspaceId" validate:"max=100"`
+	Name         string     `json:"name" validate:"max=100"`
+	Url          string     `json:"url" validate:"required,url,max=5000"`
+	ExpiresAt    *time.Time `json:"expiresAt"`
+	MaxRedirects int        `json:"maxRedirects" validate:"min=0"`
+	ExpiredUrl   string     `json:"expiredUrl" validate:"omitempty,url,max=5000"`
+	Password     string     `json:"password" validate:"omitempty,min=4,bcryptmax"`
+	Mode         LinkMode   `json:"mode" validate:"omitempty,oneof=redirect bundle"`
 }
 
+// Nil fields of UpdateLinkDTO are left untouched. A zero expiresAt, a zero
+// maxRedirects, an empty expiredUrl and an empty password remove the limit,
+// the fallback or the protection.
 type UpdateLinkDTO struct {
-	Name   string     `json:"name" validate:"min=3,max=100"`
-	Status LinkStatus `json:"status" validate:"oneof=active paused"`
+	Name         string     `json:"name" validate:"omitempty,min=3,max=100"`
+	Status       LinkStatus `json:"status" validate:"omitempty,oneof=active paused"`
+	ExpiresAt    *time.Time `json:"expiresAt"`
+	MaxRedirects *int       `json:"maxRedirects" validate:"omitempty,min=0"`
+	ExpiredUrl   *string    `json:"expiredUrl" validate:"omitempty,max=5000,eq=|url"`
+	Password     *string    `json:"password" validate:"omitempty,bcryptmax,eq=|min=4"`
+	Mode         LinkMode   `json:"mode" validate:"omitempty,oneof=redirect file bundle"`
+}
+
+// ListLinksDTO lists the personal links of the user unless WorkspaceId is set.
+type ListLinksDTO struct {
+	Limit       int      `validate:"min=1,max=100"`
+	Cursor      string   `validate:"max=1000"`
+	Status      string   `validate:"oneof=active paused expired all"`
+	Sort        LinkSort `validate:"oneof=created updated redirects"`
+	WorkspaceId string   `validate:"max=100"`
+}
+
+type LinksPage struct {
+	Links      []Link  `json:"links"`
+	NextCursor *string `json:"nextCursor"`
 }
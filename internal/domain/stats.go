@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"time"
+)
+
+type StatsInterval string
+
+const (
+	IntervalHour StatsInterval = "hour"
+	IntervalDay  StatsInterval = "day"
+	IntervalWeek StatsInterval = "week"
+)
+
+type LinkStatsDTO struct {
+	From     time.Time     `validate:"required"`
+	To       time.Time     `validate:"required,gtfield=From"`
+	Interval StatsInterval `validate:"oneof=hour day week"`
+}
+
+// UniqueVisitors is nil for hourly buckets, visitors are only tracked per day.
+type StatsBucket struct {
+	Start          time.Time `json:"start"`
+	Clicks         int       `json:"clicks"`
+	UniqueVisitors *uint64   `json:"uniqueVisitors"`
+}
+
+type StatsEntry struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
+
+type LinkStats struct {
+	LinkId         string        `json:"linkId"`
+	From           time.Time     `json:"from"`
+	To             time.Time     `json:"to"`
+	Interval       StatsInterval `json:"interval"`
+	Clicks         int           `json:"clicks"`
+	UniqueVisitors uint64        `json:"uniqueVisitors"`
+	Buckets        []StatsBucket `json:"buckets"`
+	TopReferrers   []StatsEntry  `json:"topReferrers"`
+	Devices        []StatsEntry  `json:"devices"`
+	Browsers       []StatsEntry  `json:"browsers"`
+	Countries      []StatsEntry  `json:"countries"`
+}
+
+// LinkStatsRecord keeps aggregated stats of a link for one day or for all time.
+// Visitors is a serialized HyperLogLog sketch of the unique visitors.
+type LinkStatsRecord struct {
+	LinkId   string `dynamo:"LinkId,hash"`
+	Period   string `dynamo:"Period,range"`
+	Visitors []byte `dynamo:"Visitors"`
+	Version  int    `dynamo:"Version"`
+}
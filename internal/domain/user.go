@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"time"
+)
+
+// Plan selects the limits of the user, like the max size of attachments.
+type User struct {
+	ID          string    `json:"id" dynamo:"ID,hash"`
+	Name        string    `json:"name" dynamo:"Name"`
+	Plan        string    `json:"plan" dynamo:"Plan,omitempty"`
+	DateCreated time.Time `json:"dateCreated" dynamo:"DateCreated,unixtime"`
+}
+
+// Only the SHA-256 hash of an API key is stored. The key itself is returned
+// once, in Key, when it is created. Prefix is the visible part of the key.
+type ApiKey struct {
+	ID          string     `json:"id" dynamo:"ID,hash"`
+	UserId      string     `json:"-" dynamo:"UserId"`
+	Name        string     `json:"name" dynamo:"Name"`
+	Prefix      string     `json:"prefix" dynamo:"Prefix"`
+	KeyHash     string     `json:"-" dynamo:"KeyHash"`
+	Key         string     `json:"key,omitempty" dynamo:"-"`
+	RevokedAt   *time.Time `json:"revokedAt" dynamo:"RevokedAt,unixtime"`
+	DateCreated time.Time  `json:"dateCreated" dynamo:"DateCreated,unixtime"`
+}
+
+// ID lets existing links keep their owner when their user ID is known.
+type CreateUserDTO struct {
+	ID   string `json:"id" validate:"omitempty,max=100"`
+	Name string `json:"name" validate:"required,max=100"`
+	Plan string `json:"plan" validate:"omitempty,max=50"`
+}
+
+type CreateApiKeyDTO struct {
+	Name string `json:"name" validate:"max=100"`
+}
+
+type CreatedUser struct {
+	User   *User   `json:"user"`
+	ApiKey *ApiKey `json:"apiKey"`
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"time"
+)
+
+type WorkspaceRole string
+
+const (
+	RoleOwner  WorkspaceRole = "owner"
+	RoleEditor WorkspaceRole = "editor"
+	RoleViewer WorkspaceRole = "viewer"
+)
+
+// Workspace.Version is bumped on every membership change, so changes checked
+// against the current members can be written only if nobody else changed them.
+type Workspace struct {
+	ID          string    `json:"id" dynamo:"ID,hash"`
+	Name        string    `json:"name" dynamo:"Name"`
+	DateCreated time.Time `json:"dateCreated" dynamo:"DateCreated,unixtime"`
+	Version     int       `json:"-" dynamo:"Version"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceId string        `json:"workspaceId" dynamo:"WorkspaceId,hash"`
+	UserId      string        `json:"userId" dynamo:"UserId,range"`
+	Role        WorkspaceRole `json:"role" dynamo:"Role"`
+	DateCreated time.Time     `json:"dateCreated" dynamo:"DateCreated,unixtime"`
+}
+
+// UserWorkspace is a workspace together with the role of the user in it.
+type UserWorkspace struct {
+	Workspace
+	Role WorkspaceRole `json:"role"`
+}
+
+type CreateWorkspaceDTO struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type PutWorkspaceMemberDTO struct {
+	Role WorkspaceRole `json:"role" validate:"required,oneof=owner editor viewer"`
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/the-redx/link-shortener/internal/services"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"go.uber.org/zap"
+)
+
+type AdminHandler struct {
+	reconciler *services.AttachmentReconciler
+}
+
+// ReconcileAttachments reports the orphaned attachment objects of one page of the bucket,
+// so a request fits in the timeout of the API gateway. The next page is requested with
+// ?startAfter=<nextStartAfter>, the page size with ?limit. Objects are removed only with ?remove=true.
+func (adh *AdminHandler) ReconcileAttachments(w http.ResponseWriter, r *http.Request) {
+	logger := r.Context().Value("Logger").(*zap.SugaredLogger)
+	params := r.URL.Query()
+
+	limit := services.MaxReconcilePageSize
+	if value := params.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > services.MaxReconcilePageSize {
+			writeError(w, errs.NewBadRequestError("Invalid limit"))
+			return
+		}
+
+		limit = parsed
+	}
+
+	remove := false
+	if value := params.Get("remove"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, errs.NewBadRequestError("Invalid remove"))
+			return
+		}
+
+		remove = parsed
+	}
+
+	report, err := adh.reconciler.Reconcile(remove, params.Get("startAfter"), limit, r.Context())
+	if err != nil {
+		logger.Debug("Error while reconciling attachments", zap.Error(err))
+		writeError(w, errs.NewUnexpectedError("Error while reconciling attachments"))
+		return
+	}
+
+	writeResponse(w, http.StatusOK, report)
+}
+
+func NewAdminHandler(reconciler *services.AttachmentReconciler) *AdminHandler {
+	return &AdminHandler{reconciler}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/internal/services"
+	"github.com/the-redx/link-shortener/pkg/errs"
+)
+
+type AuthHandler struct {
+	service services.AuthService
+}
+
+func (ah *AuthHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	var user domain.CreateUserDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
+		writeError(w, errs.NewBadRequestError("Invalid user data"))
+		return
+	}
+
+	if err := validate.Struct(user); err != nil {
+		writeError(w, errs.NewBadRequestError(err.Error()))
+		return
+	}
+
+	newUser, appErr := ah.service.CreateUser(&user, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, newUser)
+}
+
+func (ah *AuthHandler) GetApiKeys(w http.ResponseWriter, r *http.Request) {
+	keys, appErr := ah.service.GetApiKeys(r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, keys)
+}
+
+func (ah *AuthHandler) CreateApiKey(w http.ResponseWriter, r *http.Request) {
+	var key domain.CreateApiKeyDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		writeError(w, errs.NewBadRequestError("Invalid API key data"))
+		return
+	}
+
+	if err := validate.Struct(key); err != nil {
+		writeError(w, errs.NewBadRequestError(err.Error()))
+		return
+	}
+
+	newKey, appErr := ah.service.CreateApiKey(&key, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, newKey)
+}
+
+func (ah *AuthHandler) RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	keyId := vars["key_id"]
+
+	key, appErr := ah.service.RevokeApiKey(keyId, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, key)
+}
+
+func NewAuthHandler(service services.AuthService) *AuthHandler {
+	return &AuthHandler{service}
+}
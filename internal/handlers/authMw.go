@@ -2,35 +2,95 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
-	"os"
 	"strings"
 
+	"github.com/the-redx/link-shortener/internal/services"
 	"github.com/the-redx/link-shortener/pkg/errs"
 	"go.uber.org/zap"
 )
 
 var userIdKey = "UserID"
+var isAdminKey = "IsAdmin"
 
-func AuthMW(next http.HandlerFunc) http.HandlerFunc {
+func AuthMW(next http.HandlerFunc, auth services.AuthService, failures *FailedAuthLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := r.Context().Value("Logger").(*zap.SugaredLogger)
 
-		userId := r.Header.Get("X-User-ID")
-		userId = strings.TrimSpace(userId)
-		myUserId := os.Getenv("MY_USERID")
+		if !failures.allow(w, r) {
+			return
+		}
 
-		logger.Debugf("Auth middleware: User ID: %s", userId)
+		key, ok := bearerToken(r)
+		if !ok {
+			logger.Debug("Authentication error: no bearer token")
+			writeUnauthorized(w, errs.NewUnauthorizedError("Authentication required"))
+			return
+		}
 
-		if userId != myUserId {
-			logger.Debug("Authentication error")
-			writeError(w, errs.NewForbiddenError("Authentication error"))
+		userId, appErr := auth.Authenticate(key, r.Context())
+		if appErr != nil {
+			if appErr.Code == http.StatusUnauthorized {
+				failures.fail(r)
+				writeUnauthorized(w, appErr)
+			} else {
+				writeError(w, appErr)
+			}
 			return
 		}
 
+		logger.Debugf("Auth middleware: User ID: %s", userId)
+
 		ctx := context.WithValue(r.Context(), userIdKey, userId)
 		ctx = context.WithValue(ctx, "Logger", logger.With(zap.String("UserID", userId)))
 
 		next(w, r.WithContext(ctx))
 	}
 }
+
+// AdminMW guards the user management with a static key. The routes are
+// disabled when the key isn't set.
+func AdminMW(next http.HandlerFunc, adminKey string, failures *FailedAuthLimiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := r.Context().Value("Logger").(*zap.SugaredLogger)
+
+		if adminKey == "" {
+			writeError(w, errs.NewNotFoundError("Not found"))
+			return
+		}
+
+		if !failures.allow(w, r) {
+			return
+		}
+
+		key, ok := bearerToken(r)
+		if !ok || subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			logger.Debug("Admin authentication error")
+			if ok {
+				failures.fail(r)
+			}
+
+			writeUnauthorized(w, errs.NewUnauthorizedError("Authentication required"))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), isAdminKey, true)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func writeUnauthorized(w http.ResponseWriter, appErr *errs.AppError) {
+	w.Header().Add("WWW-Authenticate", `Bearer realm="link-shortener"`)
+	writeError(w, appErr)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+var bundlePageTemplate = template.Must(template.New("bundle").Funcs(template.FuncMap{"size": formatSize}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<meta name="robots" content="noindex">
+	<title>{{.Name}}</title>
+	<style>
+		body { font-family: system-ui, sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+		main { display: flex; flex-direction: column; gap: 12px; width: 480px; }
+		ul { list-style: none; padding: 0; margin: 0; }
+		li { display: flex; justify-content: space-between; gap: 12px; padding: 8px 0; border-bottom: 1px solid #ddd; }
+		.size { color: #777; white-space: nowrap; }
+		.button { font-size: 16px; padding: 8px; text-align: center; }
+	</style>
+</head>
+<body>
+	<main>
+		<h1>{{.Name}}</h1>
+		{{if .Files}}
+		<ul>
+			{{range .Files}}<li><a href="{{.Url}}">{{.Name}}</a><span class="size">{{size .Size}}</span></li>
+			{{end}}
+		</ul>
+		<a class="button" href="{{.ZipUrl}}">Download all as ZIP</a>
+		{{else}}
+		<p>There are no files here yet.</p>
+		{{end}}
+	</main>
+</body>
+</html>
+`))
+
+// writeBundlePage renders the download page of a bundle link.
+func writeBundlePage(w http.ResponseWriter, bundle *domain.LinkBundle) {
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+
+	bundlePageTemplate.Execute(w, bundle)
+}
+
+func formatSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	value := float64(size)
+
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
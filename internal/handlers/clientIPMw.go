@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+var clientIPKey = "ClientIP"
+var clientCountryKey = "ClientCountry"
+
+// Both CDNs send ISO 3166 alpha-2 codes
+var countryCodePattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// ClientIPMW resolves the IP and the country of the client and puts them in the context.
+// X-Forwarded-For and the country headers are used only when the request comes from
+// a trusted proxy, otherwise any client could pick the IP it is limited and counted by.
+func ClientIPMW(trustedProxies []*net.IPNet) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r)
+			country := ""
+
+			if isTrustedProxy(ip, trustedProxies) {
+				ip = forwardedIP(r, ip, trustedProxies)
+				country = forwardedCountry(r)
+			}
+
+			ctx := context.WithValue(r.Context(), clientIPKey, ip)
+			ctx = context.WithValue(ctx, clientCountryKey, country)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// forwardedIP walks X-Forwarded-For from the right and returns the first address
+// that isn't a trusted proxy. Addresses left of it could be forged by the client.
+func forwardedIP(r *http.Request, ip string, trustedProxies []*net.IPNet) string {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+
+		ip = hop
+		if !isTrustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// forwardedCountry reads the viewer country set by CloudFront or Cloudflare, anything but a country code is dropped.
+func forwardedCountry(r *http.Request) string {
+	country := r.Header.Get("CloudFront-Viewer-Country")
+	if country == "" {
+		country = r.Header.Get("CF-IPCountry")
+	}
+
+	if !countryCodePattern.MatchString(country) {
+		return ""
+	}
+
+	return country
+}
+
+func isTrustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
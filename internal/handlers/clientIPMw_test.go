@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedIP(t *testing.T) {
+	proxies := []*net.IPNet{
+		{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)},
+		{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)},
+	}
+
+	tests := []struct {
+		name      string
+		forwarded []string
+		want      string
+	}{
+		{name: "no header", want: "10.0.0.1"},
+		{name: "client", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "client behind proxies", forwarded: []string{"203.0.113.7, 10.1.1.1, 10.2.2.2"}, want: "203.0.113.7"},
+		{name: "forged address left of the client", forwarded: []string{"1.2.3.4, 203.0.113.7, 10.1.1.1"}, want: "203.0.113.7"},
+		{name: "several headers", forwarded: []string{"1.2.3.4", "203.0.113.7, 10.1.1.1"}, want: "203.0.113.7"},
+		{name: "only proxies", forwarded: []string{"10.1.1.1, 10.2.2.2"}, want: "10.1.1.1"},
+		{name: "ipv6", forwarded: []string{"2001:db9::1, 2001:db8::5"}, want: "2001:db9::1"},
+		{name: "garbage stops the walk", forwarded: []string{"203.0.113.7, unknown, 10.1.1.1"}, want: "10.1.1.1"},
+		{name: "empty hop stops the walk", forwarded: []string{"203.0.113.7,,"}, want: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+
+			if got := forwardedIP(r, "10.0.0.1", proxies); got != tt.want {
+				t.Errorf("forwardedIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPMWCountry(t *testing.T) {
+	proxies := []*net.IPNet{{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(8, 32)}}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{name: "cloudfront", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"CloudFront-Viewer-Country": "DE"}, want: "DE"},
+		{name: "cloudflare", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"CF-IPCountry": "UA"}, want: "UA"},
+		{name: "cloudfront first", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"CloudFront-Viewer-Country": "DE", "CF-IPCountry": "UA"}, want: "DE"},
+		{name: "untrusted caller", remoteAddr: "203.0.113.7:1234", headers: map[string]string{"CloudFront-Viewer-Country": "DE"}, want: ""},
+		{name: "formula", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"CF-IPCountry": "=HYPERLINK(1)"}, want: ""},
+		{name: "lowercase", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"CF-IPCountry": "de"}, want: ""},
+		{name: "tor", remoteAddr: "10.0.0.1:1234", headers: map[string]string{"CF-IPCountry": "T1"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, value := range tt.headers {
+				r.Header.Set(name, value)
+			}
+
+			var got string
+			handler := ClientIPMW(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clientCountry(r)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("clientCountry() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
@@ -12,37 +16,186 @@ import (
 	"go.uber.org/zap"
 )
 
+const mainPageUrl = "https://illiashenko.dev/link-shortener"
+
+// Large files and bundles take longer than the timeouts of the server, they get
+// this much instead. The server cancels them anyway when it shuts down.
+const transferTimeout = time.Minute * 30
+
 type LinkHandler struct {
-	service services.LinkService
+	service    services.LinkService
+	analytics  services.AnalyticsService
+	bots       *services.BotDetector
+	expiredUrl string
 }
 
-var validate = validator.New(validator.WithRequiredStructEnabled())
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// bcrypt takes at most 72 bytes, max=72 would count characters
+	v.RegisterValidation("bcryptmax", func(fl validator.FieldLevel) bool {
+		return len(fl.Field().String()) <= 72
+	})
+
+	return v
+}
 
 func (ch *LinkHandler) RedirectToLink(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	linkId := vars["link_id"]
 
-	link, appErr := ch.service.GetLinkByIDForRedirect(linkId, r.Context())
+	isBot := ch.bots.IsBot(r.Method, r.UserAgent())
+	request := services.RedirectRequest{Bot: isBot, ClientIP: clientIP(r)}
+
+	// The unlock form of a protected link posts the password to the short URL
+	if r.Method == http.MethodPost {
+		password := r.PostFormValue("password")
+		request.Password = &password
+	}
+
+	link, appErr := ch.service.GetLinkByIDForRedirect(linkId, &request, r.Context())
+	if appErr != nil && appErr.Code == http.StatusGone {
+		http.Redirect(w, r, ch.expiredUrlFor(link), http.StatusTemporaryRedirect)
+		return
+	}
+
+	if appErr != nil && link != nil && link.Protected {
+		code := appErr.Code
+		errorMessage := appErr.ErrorMessage
+		if request.Password == nil {
+			code = http.StatusOK
+			errorMessage = ""
+		}
+
+		if request.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt(max(ceilSeconds(request.RetryAfter), 1), 10))
+		}
+
+		writeUnlockPage(w, code, errorMessage)
+		return
+	}
+
 	if appErr != nil {
 		// Redirect to the main page
-		http.Redirect(w, r, "https://illiashenko.dev/link-shortener", http.StatusTemporaryRedirect)
+		http.Redirect(w, r, mainPageUrl, http.StatusTemporaryRedirect)
 		// writeError(w, appErr)
 		return
 	}
 
+	// Bots are left out of the click stats
+	if !isBot {
+		traceId, _ := r.Context().Value(traceIDKey).(string)
+		ch.analytics.RecordClick(&services.ClickInput{
+			LinkId:    link.ID,
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			IP:        clientIP(r),
+			Country:   clientCountry(r),
+			TraceId:   traceId,
+			Timestamp: time.Now(),
+		}, r.Context())
+	}
+
+	if link.Mode == domain.BundleMode {
+		bundle, appErr := ch.service.GetLinkBundle(link, r.Context())
+		if appErr != nil {
+			writeError(w, appErr)
+			return
+		}
+
+		writeBundlePage(w, bundle)
+		return
+	}
+
+	if isBot {
+		http.Redirect(w, r, link.Url, http.StatusTemporaryRedirect)
+		return
+	}
+
+	// See Other turns the form submission into a GET of the target
+	if r.Method == http.MethodPost {
+		http.Redirect(w, r, link.Url, http.StatusSeeOther)
+		return
+	}
+
 	http.Redirect(w, r, link.Url, http.StatusTemporaryRedirect)
 }
 
+// DownloadLinkBundle streams all files of a bundle link as one ZIP. The URL comes signed from the download page.
+func (ch *LinkHandler) DownloadLinkBundle(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	linkId := vars["link_id"]
+	params := r.URL.Query()
+	logger := r.Context().Value("Logger").(*zap.SugaredLogger)
+
+	link, appErr := ch.service.GetLinkBundleForZip(linkId, params.Get("expires"), params.Get("signature"), r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(transferTimeout))
+
+	w.Header().Add("Content-Type", "application/zip")
+	w.Header().Add("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": link.ID + ".zip"}))
+	w.Header().Add("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// The status is sent already, the client sees a truncated archive
+	if err := ch.service.WriteLinkBundleZip(link, w, r.Context()); err != nil {
+		logger.Warn("Error while streaming the bundle", zap.Error(err))
+	}
+}
+
 func (ch *LinkHandler) GetAllLinks(w http.ResponseWriter, r *http.Request) {
-	links, appErr := ch.service.GetAllLinks(r.Context())
+	params := r.URL.Query()
+	query := domain.ListLinksDTO{
+		Limit:  20,
+		Cursor: params.Get("cursor"),
+		Status: "active",
+		Sort:   domain.SortByCreated,
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, errs.NewBadRequestError("Invalid limit"))
+			return
+		}
+
+		query.Limit = value
+	}
+
+	if status := params.Get("status"); status != "" {
+		query.Status = status
+	}
+
+	if workspaceId := params.Get("workspace"); workspaceId != "" {
+		query.WorkspaceId = workspaceId
+	}
+
+	if sort := params.Get("sort"); sort != "" {
+		query.Sort = domain.LinkSort(sort)
+	}
+
+	if err := validate.Struct(query); err != nil {
+		writeError(w, errs.NewBadRequestError(err.Error()))
+		return
+	}
+
+	page, appErr := ch.service.GetAllLinks(&query, r.Context())
 	if appErr != nil {
 		writeError(w, appErr)
 		return
 	}
 
-	writeResponse(w, http.StatusOK, struct {
-		Links *[]domain.Link `json:"links"`
-	}{Links: links})
+	writeResponse(w, http.StatusOK, page)
 }
 
 func (ch *LinkHandler) GetLink(w http.ResponseWriter, r *http.Request) {
@@ -58,6 +211,66 @@ func (ch *LinkHandler) GetLink(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, http.StatusOK, link)
 }
 
+func (ch *LinkHandler) GetLinkStats(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	linkId := vars["link_id"]
+	params := r.URL.Query()
+
+	query := domain.LinkStatsDTO{
+		To:       time.Now(),
+		Interval: domain.IntervalDay,
+	}
+
+	if interval := params.Get("interval"); interval != "" {
+		query.Interval = domain.StatsInterval(interval)
+	}
+
+	if to := params.Get("to"); to != "" {
+		value, err := parseTimeParam(to)
+		if err != nil {
+			writeError(w, errs.NewBadRequestError("Invalid to"))
+			return
+		}
+
+		query.To = value
+	}
+
+	query.From = query.To.AddDate(0, 0, -7)
+	if from := params.Get("from"); from != "" {
+		value, err := parseTimeParam(from)
+		if err != nil {
+			writeError(w, errs.NewBadRequestError("Invalid from"))
+			return
+		}
+
+		query.From = value
+	}
+
+	if err := validate.Struct(query); err != nil {
+		writeError(w, errs.NewBadRequestError(err.Error()))
+		return
+	}
+
+	link, appErr := ch.service.GetLinkByID(linkId, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	stats, appErr := ch.analytics.GetLinkStats(link, &query, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	if params.Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		writeStatsCSV(w, link.ID, stats)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, stats)
+}
+
 func (ch *LinkHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
 	var link domain.CreateLinkDTO
 
@@ -80,22 +293,92 @@ func (ch *LinkHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, http.StatusOK, newLink)
 }
 
+// AttachFileToLink replaces all attachments of the link with the uploaded file.
 func (ch *LinkHandler) AttachFileToLink(w http.ResponseWriter, r *http.Request) {
-	// max total size 20mb
-	r.ParseMultipartForm(200 << 20)
+	ch.attachFile(w, r, true)
+}
+
+// AddAttachment adds the uploaded file to the attachments of the link.
+func (ch *LinkHandler) AddAttachment(w http.ResponseWriter, r *http.Request) {
+	ch.attachFile(w, r, false)
+}
+
+func (ch *LinkHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	linkId := vars["link_id"]
+	attachmentId := vars["attachment_id"]
+
+	link, appErr := ch.service.DeleteAttachment(linkId, attachmentId, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
 
+	writeResponse(w, http.StatusOK, link)
+}
+
+// attachFile reads the multipart body as a stream, the file part is passed on without being buffered.
+func (ch *LinkHandler) attachFile(w http.ResponseWriter, r *http.Request, replace bool) {
 	vars := mux.Vars(r)
 	linkId := vars["link_id"]
 	logger := r.Context().Value("Logger").(*zap.SugaredLogger)
 
-	file, headers, err := r.FormFile("file")
+	http.NewResponseController(w).SetReadDeadline(time.Now().Add(transferTimeout))
+
+	part, err := multipartFile(r, "file")
 	if err != nil {
 		logger.Debugf("Error reading file from form data. Reason: %s", err.Error())
 		writeError(w, errs.NewBadRequestError("Unable to attach the file"))
 		return
 	}
+	defer part.Close()
+
+	upload := services.FileUpload{
+		FileName:    part.FileName(),
+		ContentType: part.Header.Get("Content-Type"),
+		Body:        part,
+	}
 
-	newLink, appErr := ch.service.AttachFileToLinkByID(linkId, &file, headers, r.Context())
+	newLink, appErr := ch.service.AttachFileToLinkByID(linkId, &upload, replace, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, newLink)
+}
+
+func (ch *LinkHandler) CreateAttachmentUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	linkId := vars["link_id"]
+
+	var upload domain.CreateAttachmentUploadDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&upload); err != nil {
+		writeError(w, errs.NewBadRequestError("Invalid body"))
+		return
+	}
+
+	if err := validate.Struct(upload); err != nil {
+		writeError(w, errs.NewBadRequestError(err.Error()))
+		return
+	}
+
+	attachmentUpload, appErr := ch.service.CreateAttachmentUpload(linkId, &upload, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, attachmentUpload)
+}
+
+func (ch *LinkHandler) CompleteAttachmentUpload(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	linkId := vars["link_id"]
+	uploadId := vars["upload_id"]
+
+	newLink, appErr := ch.service.CompleteAttachmentUpload(linkId, uploadId, r.Context())
 	if appErr != nil {
 		writeError(w, appErr)
 		return
@@ -142,6 +425,19 @@ func (ch *LinkHandler) DeleteLink(w http.ResponseWriter, r *http.Request) {
 	writeResponse(w, http.StatusOK, link)
 }
 
-func NewLinkHandler(service services.LinkService) *LinkHandler {
-	return &LinkHandler{service}
+// expiredUrlFor prefers the fallback of the link over the global one.
+func (ch *LinkHandler) expiredUrlFor(link *domain.Link) string {
+	if link != nil && link.ExpiredUrl != "" {
+		return link.ExpiredUrl
+	}
+
+	if ch.expiredUrl != "" {
+		return ch.expiredUrl
+	}
+
+	return mainPageUrl
+}
+
+func NewLinkHandler(service services.LinkService, analytics services.AnalyticsService, bots *services.BotDetector, expiredUrl string) *LinkHandler {
+	return &LinkHandler{service, analytics, bots, expiredUrl}
 }
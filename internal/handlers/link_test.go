@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gorilla/mux"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/internal/services"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Requests without a user agent are taken for bots
+const browserUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+
+type testServer struct {
+	router *mux.Router
+	auth   services.AuthService
+}
+
+// newTestServer wires the link and API key routes over the memory repositories like main does.
+func newTestServer(t *testing.T) *testServer {
+	utils.Logger = zap.NewNop().Sugar()
+
+	links := services.NewMemoryLinkRepository()
+	clicks := services.NewMemoryClickRepository()
+	stats := services.NewMemoryLinkStatsRepository()
+	users := services.NewMemoryUserRepository()
+	workspaces := services.NewMemoryWorkspaceRepository()
+	s3Client := s3.New(s3.Options{Region: "us-east-1"})
+
+	linkService := services.NewLinkService(links, stats, services.NewDirectRedirectCounter(links), services.NewAuthorizer(workspaces), users, s3Client, services.S3Options{}, services.AttachmentOptions{}, "")
+	authService := services.NewAuthService(users, services.NewMemoryApiKeyRepository(), nil)
+
+	visitors := services.NewVisitorCounter(stats, time.Minute)
+	t.Cleanup(func() {
+		visitors.Close(context.Background())
+	})
+
+	analytics := services.NewAnalyticsService(clicks, stats, services.NewClickRecorder(clicks, 10, 0), visitors, "salt")
+	bots, err := services.NewBotDetector(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	factory, err := services.NewRateLimiterFactory(services.RateLimiterOptions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures := NewFailedAuthLimiter(services.NewKeyedRateLimiter("auth", services.RateLimitPolicy{Capacity: 100, Window: time.Minute}, 100, factory))
+	ch := NewLinkHandler(linkService, analytics, bots, "https://expired.example")
+	ah := NewAuthHandler(authService)
+
+	router := mux.NewRouter()
+	router.Use(LogMW)
+	router.Use(ClientIPMW(nil))
+
+	router.HandleFunc("/links", AuthMW(ch.CreateLink, authService, failures)).Methods(http.MethodPost)
+	router.HandleFunc("/links/{link_id}", AuthMW(ch.GetLink, authService, failures)).Methods(http.MethodGet)
+	router.HandleFunc("/api-keys/{key_id}", AuthMW(ah.RevokeApiKey, authService, failures)).Methods(http.MethodDelete)
+	router.HandleFunc("/{link_id}", ch.RedirectToLink).Methods(http.MethodGet, http.MethodHead, http.MethodPost)
+
+	return &testServer{router: router, auth: authService}
+}
+
+// createUser returns the API key of the new user.
+func (s *testServer) createUser(t *testing.T, id string) *domain.ApiKey {
+	ctx := context.WithValue(context.Background(), "Logger", utils.Logger)
+
+	user, appErr := s.auth.CreateUser(&domain.CreateUserDTO{ID: id, Name: id}, ctx)
+	if appErr != nil {
+		t.Fatal(appErr.ErrorMessage)
+	}
+
+	return user.ApiKey
+}
+
+func (s *testServer) do(method string, target string, authorization string, contentType string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("User-Agent", browserUserAgent)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, r)
+	return w
+}
+
+func (s *testServer) createLink(t *testing.T, key string, body string) {
+	if w := s.do(http.MethodPost, "/links", "Bearer "+key, "application/json", body); w.Code != http.StatusOK {
+		t.Fatalf("POST /links %s = %d %s", body, w.Code, w.Body)
+	}
+}
+
+func TestRedirectToLink(t *testing.T) {
+	server := newTestServer(t)
+	key := server.createUser(t, "alice").Key
+
+	server.createLink(t, key, `{"id":"plain","url":"https://example.com/plain"}`)
+	server.createLink(t, key, `{"id":"once","url":"https://example.com/once","maxRedirects":1,"expiredUrl":"https://example.com/gone"}`)
+	server.createLink(t, key, `{"id":"dated","url":"https://example.com/dated","expiresAt":"`+time.Now().Add(time.Hour).Format(time.RFC3339)+`"}`)
+	server.createLink(t, key, `{"id":"secret","url":"https://example.com/secret","password":"right-password"}`)
+
+	steps := []struct {
+		name         string
+		method       string
+		path         string
+		form         url.Values
+		wantCode     int
+		wantLocation string
+	}{
+		{name: "redirect", method: http.MethodGet, path: "/plain", wantCode: http.StatusTemporaryRedirect, wantLocation: "https://example.com/plain"},
+		{name: "missing link", method: http.MethodGet, path: "/missing", wantCode: http.StatusTemporaryRedirect, wantLocation: mainPageUrl},
+		{name: "last redirect", method: http.MethodGet, path: "/once", wantCode: http.StatusTemporaryRedirect, wantLocation: "https://example.com/once"},
+		{name: "redirects used up", method: http.MethodGet, path: "/once", wantCode: http.StatusTemporaryRedirect, wantLocation: "https://example.com/gone"},
+		{name: "not expired yet", method: http.MethodGet, path: "/dated", wantCode: http.StatusTemporaryRedirect, wantLocation: "https://example.com/dated"},
+		{name: "unlock page", method: http.MethodGet, path: "/secret", wantCode: http.StatusOK},
+		{name: "wrong password", method: http.MethodPost, path: "/secret", form: url.Values{"password": {"wrong"}}, wantCode: http.StatusUnauthorized},
+		{name: "right password", method: http.MethodPost, path: "/secret", form: url.Values{"password": {"right-password"}}, wantCode: http.StatusSeeOther, wantLocation: "https://example.com/secret"},
+	}
+
+	for _, step := range steps {
+		contentType := ""
+		if step.form != nil {
+			contentType = "application/x-www-form-urlencoded"
+		}
+
+		w := server.do(step.method, step.path, "", contentType, step.form.Encode())
+		if w.Code != step.wantCode {
+			t.Fatalf("%s: %s %s = %d, want %d", step.name, step.method, step.path, w.Code, step.wantCode)
+		}
+
+		if location := w.Header().Get("Location"); location != step.wantLocation {
+			t.Errorf("%s: Location = %q, want %q", step.name, location, step.wantLocation)
+		}
+	}
+}
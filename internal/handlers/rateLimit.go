@@ -1,8 +1,11 @@
 package handlers
 
 import (
-	"context"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/mennanov/limiters"
 	"github.com/the-redx/link-shortener/internal/services"
@@ -10,21 +13,126 @@ import (
 	"go.uber.org/zap"
 )
 
-func RateLimitMW(next http.HandlerFunc, limiter services.RateLimiter) http.HandlerFunc {
+// RateLimitMW sets the RateLimit headers on every response and answers with 429 and Retry-After once the quota is used up.
+func RateLimitMW(next http.HandlerFunc, limiter *services.KeyedRateLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := r.Context().Value("Logger").(*zap.SugaredLogger)
-		duration, err := limiter.Limit(context.TODO())
+		status, err := limiter.Limit(rateLimitKey(r), r.Context())
 
-		if err == limiters.ErrLimitExhausted {
-			logger.Debugf("Rate limit exceeded. Try again in %d seconds", int32(duration.Seconds()))
-			writeError(w, errs.NewBadRequestError("Rate limit exceeded. Try later"))
-			return
-		} else if err != nil {
-			logger.Debugf("Rate limiter error", err.Error())
+		if err != nil && err != limiters.ErrLimitExhausted {
+			logger.Debug("Rate limiter error", zap.Error(err))
 			writeError(w, errs.NewUnexpectedError("Something went wrong"))
 			return
 		}
 
+		w.Header().Set("RateLimit-Limit", strconv.FormatInt(status.Limit, 10))
+		w.Header().Set("RateLimit-Remaining", strconv.FormatInt(status.Remaining, 10))
+		w.Header().Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(status.Reset), 10))
+
+		if err == limiters.ErrLimitExhausted {
+			retryAfter := max(ceilSeconds(status.RetryAfter), 1)
+
+			logger.Debugf("Rate limit exceeded. Try again in %d seconds", retryAfter)
+			w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+			writeError(w, errs.NewTooManyRequestsError("Rate limit exceeded. Try later"))
+			return
+		}
+
 		next(w, r)
 	}
 }
+
+// Headers carry whole seconds, rounding down would tell clients to retry too early
+func ceilSeconds(duration time.Duration) int64 {
+	return int64(math.Ceil(duration.Seconds()))
+}
+
+// rateLimitKey identifies the client by the identity AuthMW or AdminMW has verified,
+// otherwise by the client IP. An unverified bearer token can't be the key, a client
+// could send a new one with every request and never run out of quota.
+func rateLimitKey(r *http.Request) string {
+	if userId, ok := r.Context().Value(userIdKey).(string); ok && userId != "" {
+		return "user:" + userId
+	}
+
+	if isAdmin, _ := r.Context().Value(isAdminKey).(bool); isAdmin {
+		return "admin"
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// FailedAuthLimiter counts failed authentications by client IP. The credentials are
+// checked before RateLimitMW runs, so without it guessing keys would never be limited.
+// Once an IP has used up its quota, its requests are turned away before their
+// credentials are checked until the quota resets.
+type FailedAuthLimiter struct {
+	limiter *services.KeyedRateLimiter
+
+	mu      sync.Mutex
+	blocked map[string]time.Time
+}
+
+func NewFailedAuthLimiter(limiter *services.KeyedRateLimiter) *FailedAuthLimiter {
+	return &FailedAuthLimiter{limiter: limiter, blocked: make(map[string]time.Time)}
+}
+
+// allow answers with 429 and Retry-After when the IP of the request is blocked
+func (l *FailedAuthLimiter) allow(w http.ResponseWriter, r *http.Request) bool {
+	retryAfter := l.retryAfter(clientIP(r), time.Now())
+	if retryAfter <= 0 {
+		return true
+	}
+
+	logger := r.Context().Value("Logger").(*zap.SugaredLogger)
+	logger.Debug("Too many failed authentications")
+
+	w.Header().Set("Retry-After", strconv.FormatInt(max(ceilSeconds(retryAfter), 1), 10))
+	writeError(w, errs.NewTooManyRequestsError("Too many failed authentications. Try later"))
+	return false
+}
+
+func (l *FailedAuthLimiter) retryAfter(ip string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until, ok := l.blocked[ip]
+	if !ok {
+		return 0
+	}
+
+	if !now.Before(until) {
+		delete(l.blocked, ip)
+		return 0
+	}
+
+	return until.Sub(now)
+}
+
+// fail counts a failed authentication of the IP of the request
+func (l *FailedAuthLimiter) fail(r *http.Request) {
+	ip := clientIP(r)
+
+	status, err := l.limiter.Limit("ip:"+ip, r.Context())
+	if err != limiters.ErrLimitExhausted {
+		if err != nil {
+			logger := r.Context().Value("Logger").(*zap.SugaredLogger)
+			logger.Debug("Rate limiter error", zap.Error(err))
+		}
+
+		return
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for blockedIP, until := range l.blocked {
+		if !now.Before(until) {
+			delete(l.blocked, blockedIP)
+		}
+	}
+
+	l.blocked[ip] = now.Add(max(status.RetryAfter, time.Second))
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+)
+
+var unlockPageTemplate = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<meta name="robots" content="noindex">
+	<title>Protected link</title>
+	<style>
+		body { font-family: system-ui, sans-serif; display: flex; justify-content: center; margin-top: 15vh; }
+		form { display: flex; flex-direction: column; gap: 12px; width: 280px; }
+		input, button { font-size: 16px; padding: 8px; }
+		.error { color: #c0392b; margin: 0; }
+	</style>
+</head>
+<body>
+	<form method="post">
+		<h1>Protected link</h1>
+		<p>Enter the password to open this link.</p>
+		{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+		<input type="password" name="password" autocomplete="current-password" autofocus required>
+		<button type="submit">Open</button>
+	</form>
+</body>
+</html>
+`))
+
+type unlockPage struct {
+	Error string
+}
+
+// writeUnlockPage renders the password form of a protected link.
+func writeUnlockPage(w http.ResponseWriter, code int, errorMessage string) {
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Referrer-Policy", "no-referrer")
+	w.WriteHeader(code)
+
+	unlockPageTemplate.Execute(w, unlockPage{Error: errorMessage})
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang-cz/nilslice"
+	"github.com/the-redx/link-shortener/internal/domain"
 	"github.com/the-redx/link-shortener/pkg/errs"
 )
 
@@ -20,3 +26,89 @@ func writeResponse(w http.ResponseWriter, code int, data interface{}) {
 func writeError(w http.ResponseWriter, appErr *errs.AppError) {
 	writeResponse(w, appErr.Code, appErr)
 }
+
+// clientIP returns the IP resolved by ClientIPMW, or the remote address when the middleware isn't used.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+
+	return remoteIP(r)
+}
+
+// clientCountry returns the country resolved by ClientIPMW, it's empty unless a trusted proxy sent it.
+func clientCountry(r *http.Request) string {
+	country, _ := r.Context().Value(clientCountryKey).(string)
+	return country
+}
+
+// parseTimeParam accepts either RFC 3339 timestamps or plain dates.
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
+
+func writeStatsCSV(w http.ResponseWriter, linkId string, stats *domain.LinkStats) {
+	w.Header().Add("Content-Type", "text/csv")
+	w.Header().Add("Content-Disposition", `attachment; filename="`+linkId+`-stats.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"type", "value", "clicks"})
+
+	for _, bucket := range stats.Buckets {
+		writer.Write([]string{"bucket", bucket.Start.Format(time.RFC3339), strconv.Itoa(bucket.Clicks)})
+	}
+
+	sections := []struct {
+		name    string
+		entries []domain.StatsEntry
+	}{
+		{"referrer", stats.TopReferrers},
+		{"device", stats.Devices},
+		{"browser", stats.Browsers},
+		{"country", stats.Countries},
+	}
+
+	for _, section := range sections {
+		for _, entry := range section.entries {
+			writer.Write([]string{section.name, csvCell(entry.Value), strconv.Itoa(entry.Clicks)})
+		}
+	}
+
+	writer.Flush()
+}
+
+// csvCell keeps spreadsheets from evaluating values that come from clients, like
+// a referrer of =HYPERLINK(...), by prefixing cells that would start a formula.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+
+	return value
+}
+
+// multipartFile skips the parts of a multipart body until the file field with the given name.
+func multipartFile(r *http.Request, name string) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == name && part.FileName() != "" {
+			return part, nil
+		}
+
+		part.Close()
+	}
+}
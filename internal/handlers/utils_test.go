@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+func TestCsvCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "", want: ""},
+		{value: "news.ycombinator.com", want: "news.ycombinator.com"},
+		{value: "(direct)", want: "(direct)"},
+		{value: "a=b", want: "a=b"},
+		{value: `=HYPERLINK("https://evil.example","x")`, want: `'=HYPERLINK("https://evil.example","x")`},
+		{value: "+1+2", want: "'+1+2"},
+		{value: "-2+3", want: "'-2+3"},
+		{value: "@SUM(A1)", want: "'@SUM(A1)"},
+		{value: "\t=1", want: "'\t=1"},
+		{value: "\r=1", want: "'\r=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := csvCell(tt.value); got != tt.want {
+				t.Errorf("csvCell(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteStatsCSVNeutralisesFormulas(t *testing.T) {
+	stats := &domain.LinkStats{
+		Buckets:      []domain.StatsBucket{{Start: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Clicks: 2}},
+		TopReferrers: []domain.StatsEntry{{Value: "=cmd|' /C calc'!A0", Clicks: 1}},
+		Countries:    []domain.StatsEntry{{Value: "@HYPERLINK(1)", Clicks: 1}},
+	}
+
+	w := httptest.NewRecorder()
+	writeStatsCSV(w, "abc", stats)
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+
+	want := [][]string{
+		{"type", "value", "clicks"},
+		{"bucket", "2026-01-01T00:00:00Z", "2"},
+		{"referrer", "'=cmd|' /C calc'!A0", "1"},
+		{"country", "'@HYPERLINK(1)", "1"},
+	}
+
+	if len(records) != len(want) {
+		t.Fatalf("records = %q, want %q", records, want)
+	}
+
+	for i := range want {
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/internal/services"
+	"github.com/the-redx/link-shortener/pkg/errs"
+)
+
+type WorkspaceHandler struct {
+	service services.WorkspaceService
+}
+
+func (wh *WorkspaceHandler) GetWorkspaces(w http.ResponseWriter, r *http.Request) {
+	workspaces, appErr := wh.service.GetWorkspaces(r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, workspaces)
+}
+
+func (wh *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	var workspace domain.CreateWorkspaceDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&workspace); err != nil {
+		writeError(w, errs.NewBadRequestError("Invalid workspace data"))
+		return
+	}
+
+	if err := validate.Struct(workspace); err != nil {
+		writeError(w, errs.NewBadRequestError(err.Error()))
+		return
+	}
+
+	newWorkspace, appErr := wh.service.CreateWorkspace(&workspace, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, newWorkspace)
+}
+
+func (wh *WorkspaceHandler) GetWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workspaceId := vars["workspace_id"]
+
+	members, appErr := wh.service.GetWorkspaceMembers(workspaceId, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, members)
+}
+
+func (wh *WorkspaceHandler) PutWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workspaceId := vars["workspace_id"]
+	userId := vars["user_id"]
+
+	var member domain.PutWorkspaceMemberDTO
+
+	if err := json.NewDecoder(r.Body).Decode(&member); err != nil {
+		writeError(w, errs.NewBadRequestError("Invalid member data"))
+		return
+	}
+
+	if err := validate.Struct(member); err != nil {
+		writeError(w, errs.NewBadRequestError(err.Error()))
+		return
+	}
+
+	newMember, appErr := wh.service.PutWorkspaceMember(workspaceId, userId, &member, r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, newMember)
+}
+
+func (wh *WorkspaceHandler) DeleteWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	workspaceId := vars["workspace_id"]
+	userId := vars["user_id"]
+
+	if appErr := wh.service.DeleteWorkspaceMember(workspaceId, userId, r.Context()); appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func NewWorkspaceHandler(service services.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{service}
+}
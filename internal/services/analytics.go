@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"github.com/the-redx/link-shortener/pkg/hll"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	maxStatsBuckets = 1000
+	topStatsEntries = 10
+	// Distinct values counted per breakdown, the rest is counted as (other)
+	maxStatsValues = 10000
+)
+
+type ClickInput struct {
+	LinkId    string
+	Referrer  string
+	UserAgent string
+	IP        string
+	Country   string
+	TraceId   string
+	Timestamp time.Time
+}
+
+type AnalyticsService struct {
+	clicks   ClickRepository
+	stats    LinkStatsRepository
+	recorder *ClickRecorder
+	visitors *VisitorCounter
+	ipSalt   string
+}
+
+func (s *AnalyticsService) RecordClick(input *ClickInput, ctx context.Context) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	userAgent := utils.ParseUserAgent(input.UserAgent)
+
+	click := domain.Click{
+		LinkId:    input.LinkId,
+		ClickId:   newClickId(input.Timestamp),
+		Timestamp: input.Timestamp,
+		Referrer:  referrerHost(input.Referrer),
+		Browser:   userAgent.Browser,
+		Device:    userAgent.Device,
+		Country:   countryCode(input.Country),
+		IPHash:    s.hashIP(input.IP),
+		TraceId:   input.TraceId,
+	}
+
+	s.visitors.Add(input.LinkId, input.Timestamp, s.visitorHash(input.IP, input.UserAgent))
+
+	if !s.recorder.Record(&click) {
+		logger.Warn("Click queue is full. Click is dropped")
+		return
+	}
+
+	logger.Debug("Click recorded", zap.Any("click", click))
+}
+
+// countryCode drops anything but an ISO 3166 alpha-2 code, the value ends up in the stats export
+func countryCode(country string) string {
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		return ""
+	}
+
+	return country
+}
+
+func (s *AnalyticsService) GetLinkStats(link *domain.Link, query *domain.LinkStatsDTO, ctx context.Context) (*domain.LinkStats, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	from := truncateToInterval(query.From.UTC(), query.Interval)
+	to := query.To.UTC()
+
+	var buckets []domain.StatsBucket
+	for start := from; start.Before(to); start = nextInterval(start, query.Interval) {
+		if len(buckets) == maxStatsBuckets {
+			logger.Debugf("Too many buckets for interval %s", query.Interval)
+			return nil, errs.NewBadRequestError("Time range is too long for the interval")
+		}
+
+		buckets = append(buckets, domain.StatsBucket{Start: start})
+	}
+
+	referrers := make(map[string]int)
+	devices := make(map[string]int)
+	browsers := make(map[string]int)
+	countries := make(map[string]int)
+
+	// The clicks are aggregated while they're read, only the counts are kept
+	total := 0
+	err := s.clicks.EachByLink(link.ID, from, to, func(click *domain.Click) {
+		bucketStart := truncateToInterval(click.Timestamp.UTC(), query.Interval)
+		index := sort.Search(len(buckets), func(i int) bool {
+			return !buckets[i].Start.Before(bucketStart)
+		})
+		if index < len(buckets) && buckets[index].Start.Equal(bucketStart) {
+			buckets[index].Clicks++
+		}
+
+		total++
+		countStatsValue(referrers, valueOrDefault(click.Referrer, "(direct)"))
+		countStatsValue(devices, valueOrDefault(click.Device, "unknown"))
+		countStatsValue(browsers, valueOrDefault(click.Browser, "Other"))
+		countStatsValue(countries, valueOrDefault(click.Country, "unknown"))
+	}, ctx)
+	if err != nil {
+		logger.Debug("Error while fetching clicks", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while fetching stats")
+	}
+
+	dailyVisitors, err := s.stats.ListDailyVisitors(link.ID, from.Format(statsDayFormat), to.Format(statsDayFormat), ctx)
+	if err != nil {
+		logger.Debug("Error while fetching visitors", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while fetching stats")
+	}
+
+	visitors := hll.New()
+	bucketVisitors := make(map[time.Time]*hll.Sketch)
+
+	for day, sketch := range dailyVisitors {
+		visitors.Merge(sketch)
+
+		dayStart, err := time.Parse(statsDayFormat, day)
+		if err != nil {
+			continue
+		}
+
+		bucketStart := truncateToInterval(dayStart, query.Interval)
+		if bucketVisitors[bucketStart] == nil {
+			bucketVisitors[bucketStart] = hll.New()
+		}
+
+		bucketVisitors[bucketStart].Merge(sketch)
+	}
+
+	if query.Interval != domain.IntervalHour {
+		for i := range buckets {
+			var estimate uint64
+			if sketch, ok := bucketVisitors[buckets[i].Start]; ok {
+				estimate = sketch.Estimate()
+			}
+
+			buckets[i].UniqueVisitors = &estimate
+		}
+	}
+
+	stats := domain.LinkStats{
+		LinkId:         link.ID,
+		From:           from,
+		To:             to,
+		Interval:       query.Interval,
+		Clicks:         total,
+		UniqueVisitors: visitors.Estimate(),
+		Buckets:        buckets,
+		TopReferrers:   topStatsEntriesOf(referrers),
+		Devices:        topStatsEntriesOf(devices),
+		Browsers:       topStatsEntriesOf(browsers),
+		Countries:      topStatsEntriesOf(countries),
+	}
+
+	logger.Debug("Stats calculated", zap.Int("clicks", stats.Clicks))
+	return &stats, nil
+}
+
+// visitorHash identifies a visitor by a salted hash of the full IP and the user agent,
+// only the HyperLogLog registers derived from it are stored.
+func (s *AnalyticsService) visitorHash(ip string, userAgent string) uint64 {
+	sum := sha256.Sum256([]byte(s.ipSalt + "\n" + ip + "\n" + userAgent))
+
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// hashIP truncates the address to its /24 (IPv4) or /48 (IPv6) network before
+// hashing it, so the stored value can't be turned back into a visitor's address.
+func (s *AnalyticsService) hashIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+
+	var network net.IP
+	if ipv4 := parsed.To4(); ipv4 != nil {
+		network = ipv4.Mask(net.CIDRMask(24, 32))
+	} else {
+		network = parsed.Mask(net.CIDRMask(48, 128))
+	}
+
+	sum := sha256.Sum256([]byte(s.ipSalt + network.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+
+	return parsed.Hostname()
+}
+
+func truncateToInterval(t time.Time, interval domain.StatsInterval) time.Time {
+	switch interval {
+	case domain.IntervalHour:
+		return t.Truncate(time.Hour)
+	case domain.IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func nextInterval(t time.Time, interval domain.StatsInterval) time.Time {
+	switch interval {
+	case domain.IntervalHour:
+		return t.Add(time.Hour)
+	case domain.IntervalWeek:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func countStatsValue(counts map[string]int, value string) {
+	if _, ok := counts[value]; !ok && len(counts) >= maxStatsValues {
+		value = "(other)"
+	}
+
+	counts[value]++
+}
+
+func topStatsEntriesOf(counts map[string]int) []domain.StatsEntry {
+	entries := make([]domain.StatsEntry, 0, len(counts))
+	for value, clicks := range counts {
+		entries = append(entries, domain.StatsEntry{Value: value, Clicks: clicks})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Clicks != entries[j].Clicks {
+			return entries[i].Clicks > entries[j].Clicks
+		}
+
+		return entries[i].Value < entries[j].Value
+	})
+
+	if len(entries) > topStatsEntries {
+		entries = entries[:topStatsEntries]
+	}
+
+	return entries
+}
+
+func valueOrDefault(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+func NewAnalyticsService(clicks ClickRepository, stats LinkStatsRepository, recorder *ClickRecorder, visitors *VisitorCounter, ipSalt string) AnalyticsService {
+	return AnalyticsService{clicks: clicks, stats: stats, recorder: recorder, visitors: visitors, ipSalt: ipSalt}
+}
@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+var (
+	ErrApiKeyNotFound      = errors.New("api key not found")
+	ErrApiKeyAlreadyExists = errors.New("api key already exists")
+)
+
+type ApiKeyRepository interface {
+	Get(id string, ctx context.Context) (*domain.ApiKey, error)
+	// Put fails with ErrApiKeyAlreadyExists if a key with the same ID exists.
+	Put(key *domain.ApiKey, ctx context.Context) error
+	// ListByUser returns the keys of the user, newest first, revoked keys included.
+	ListByUser(userId string, ctx context.Context) ([]domain.ApiKey, error)
+	// Revoke fails with ErrApiKeyNotFound unless the key exists and belongs to the user.
+	Revoke(id string, userId string, revokedAt time.Time, ctx context.Context) (*domain.ApiKey, error)
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/dynamo/v2"
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+var apiKeysByUserIndex = dynamo.Index{
+	Name:           "UserId-DateCreated-index",
+	HashKey:        "UserId",
+	HashKeyType:    dynamo.StringType,
+	RangeKey:       "DateCreated",
+	RangeKeyType:   dynamo.NumberType,
+	ProjectionType: dynamo.AllProjection,
+}
+
+type DynamoDBApiKeyRepository struct {
+	apiKeysTable dynamo.Table
+}
+
+func (r *DynamoDBApiKeyRepository) Get(id string, ctx context.Context) (*domain.ApiKey, error) {
+	var key domain.ApiKey
+
+	if err := r.apiKeysTable.Get("ID", id).One(ctx, &key); err != nil {
+		if err == dynamo.ErrNotFound {
+			return nil, ErrApiKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func (r *DynamoDBApiKeyRepository) Put(key *domain.ApiKey, ctx context.Context) error {
+	err := r.apiKeysTable.Put(key).If("attribute_not_exists('ID')").Run(ctx)
+	if dynamo.IsCondCheckFailed(err) {
+		return ErrApiKeyAlreadyExists
+	}
+
+	return err
+}
+
+func (r *DynamoDBApiKeyRepository) ListByUser(userId string, ctx context.Context) ([]domain.ApiKey, error) {
+	var keys []domain.ApiKey
+
+	err := r.apiKeysTable.Get("UserId", userId).Index(apiKeysByUserIndex.Name).Order(dynamo.Descending).All(ctx, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+func (r *DynamoDBApiKeyRepository) Revoke(id string, userId string, revokedAt time.Time, ctx context.Context) (*domain.ApiKey, error) {
+	var key domain.ApiKey
+
+	err := r.apiKeysTable.Update("ID", id).
+		If("attribute_exists('ID') AND 'UserId' = ?", userId).
+		Set("RevokedAt", revokedAt.Unix()).
+		Value(ctx, &key)
+	if err != nil {
+		if dynamo.IsCondCheckFailed(err) {
+			return nil, ErrApiKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func NewDynamoDBApiKeyRepository(db *dynamo.DB) *DynamoDBApiKeyRepository {
+	table := GetOrCreateTable(db, "ApiKeys", domain.ApiKey{}, apiKeysByUserIndex)
+
+	return &DynamoDBApiKeyRepository{apiKeysTable: table}
+}
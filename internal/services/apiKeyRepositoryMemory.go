@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+// MemoryApiKeyRepository keeps API keys in process memory.
+type MemoryApiKeyRepository struct {
+	mu   sync.RWMutex
+	keys map[string]domain.ApiKey
+}
+
+func (r *MemoryApiKeyRepository) Get(id string, ctx context.Context) (*domain.ApiKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, ErrApiKeyNotFound
+	}
+
+	return &key, nil
+}
+
+func (r *MemoryApiKeyRepository) Put(key *domain.ApiKey, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[key.ID]; ok {
+		return ErrApiKeyAlreadyExists
+	}
+
+	r.keys[key.ID] = *key
+	return nil
+}
+
+func (r *MemoryApiKeyRepository) ListByUser(userId string, ctx context.Context) ([]domain.ApiKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []domain.ApiKey
+	for _, key := range r.keys {
+		if key.UserId == userId {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].DateCreated.After(keys[j].DateCreated)
+	})
+
+	return keys, nil
+}
+
+func (r *MemoryApiKeyRepository) Revoke(id string, userId string, revokedAt time.Time, ctx context.Context) (*domain.ApiKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok || key.UserId != userId {
+		return nil, ErrApiKeyNotFound
+	}
+
+	key.RevokedAt = &revokedAt
+	r.keys[id] = key
+	return &key, nil
+}
+
+func NewMemoryApiKeyRepository() *MemoryApiKeyRepository {
+	return &MemoryApiKeyRepository{keys: make(map[string]domain.ApiKey)}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// AttachmentCleaner removes the objects of attachments that aren't used
+// anymore. With a retention prefix the objects are moved under it instead,
+// so they can still be restored until a lifecycle rule of the bucket expires them.
+type AttachmentCleaner struct {
+	s3              *s3.Client
+	bucket          string
+	retentionPrefix string
+}
+
+func (c *AttachmentCleaner) Remove(key string, ctx context.Context) error {
+	if c.retentionPrefix != "" {
+		_, err := c.s3.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:     aws.String(c.bucket),
+			Key:        aws.String(c.retentionPrefix + key),
+			CopySource: aws.String(c.bucket + "/" + url.PathEscape(key)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := c.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+func NewAttachmentCleaner(s3Client *s3.Client, bucket string, retentionPrefix string) *AttachmentCleaner {
+	return &AttachmentCleaner{s3: s3Client, bucket: bucket, retentionPrefix: retentionPrefix}
+}
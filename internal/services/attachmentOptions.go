@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// Enough of the file for mimetype to recognize every format it knows
+const sniffedBytes = 3072
+
+var errFileTooLarge = errors.New("file is too large")
+
+// AttachmentOptions limits the files that can be attached to links. MaxSize
+// is used for users without a plan in PlanMaxSizes. Empty ContentTypes allow
+// every type that isn't denied, an entry like "image/*" matches a whole family.
+// Replaced and deleted attachments are moved under RetentionPrefix when it's set.
+// SigningKey signs the ZIP download URLs of bundle links.
+type AttachmentOptions struct {
+	MaxSize            int64
+	PlanMaxSizes       map[string]int64
+	ContentTypes       []string
+	DeniedContentTypes []string
+	RetentionPrefix    string
+	SigningKey         string
+}
+
+// checkContentType takes a media type without parameters. Denied types win over allowed ones.
+func (o *AttachmentOptions) checkContentType(contentType string) *errs.AppError {
+	if matchesContentType(o.DeniedContentTypes, contentType) {
+		return errs.NewUnsupportedMediaTypeError("Files of type " + contentType + " can't be attached")
+	}
+
+	if len(o.ContentTypes) > 0 && !matchesContentType(o.ContentTypes, contentType) {
+		return errs.NewUnsupportedMediaTypeError("Files of type " + contentType + " can't be attached")
+	}
+
+	return nil
+}
+
+func matchesContentType(patterns []string, contentType string) bool {
+	for _, pattern := range patterns {
+		family, ok := strings.CutSuffix(pattern, "/*")
+		if pattern == contentType || (ok && strings.HasPrefix(contentType, family+"/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxAttachmentSize is the limit of the plan of the user who uploads. Users
+// that can't be found, like the ones of JWTs, get the default limit.
+func (s *LinkService) maxAttachmentSize(ctx context.Context) int64 {
+	userId, _ := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	user, err := s.users.Get(userId, ctx)
+	if err != nil {
+		if err != ErrUserNotFound {
+			logger.Debug("Error while fetching the user", zap.Error(err))
+		}
+
+		return s.attachments.MaxSize
+	}
+
+	if maxSize, ok := s.attachments.PlanMaxSizes[user.Plan]; ok {
+		return maxSize
+	}
+
+	return s.attachments.MaxSize
+}
+
+// sniffContentType detects the type of a file from its first bytes. The
+// returned reader still starts at the beginning of the file.
+func sniffContentType(body io.Reader) (*mimetype.MIME, io.Reader, error) {
+	head := make([]byte, sniffedBytes)
+
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, nil, err
+	}
+
+	head = head[:n]
+	return mimetype.Detect(head), io.MultiReader(bytes.NewReader(head), body), nil
+}
+
+// matchesDeclaredType allows a declared type that's more specific than what
+// was sniffed only for text, as text formats mostly can't be told apart.
+func matchesDeclaredType(sniffed *mimetype.MIME, declared string) bool {
+	if sniffed.Is(declared) {
+		return true
+	}
+
+	return sniffed.Is("text/plain") && strings.HasPrefix(declared, "text/")
+}
+
+// mediaType drops the parameters of a content type, "text/plain; charset=utf-8" becomes "text/plain".
+func mediaType(contentType string) string {
+	value, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+
+	return value
+}
+
+// limitedReader fails with errFileTooLarge once more than limit bytes are read.
+type limitedReader struct {
+	reader io.Reader
+	limit  int64
+	size   int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.size += int64(n)
+
+	if r.size > r.limit {
+		return n, errFileTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// Objects younger than this are skipped, they may belong to a browser upload that isn't completed yet
+const orphanGracePeriod = time.Hour * 24
+
+// AttachmentReconciler finds objects of the attachments bucket that no link
+// points to, like files of uploads that were never completed or that were
+// left behind when removing them failed.
+type AttachmentReconciler struct {
+	repo    LinkRepository
+	s3      *s3.Client
+	bucket  string
+	cleaner *AttachmentCleaner
+
+	mu       sync.Mutex
+	running  bool
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// MaxReconcilePageSize is the most objects one Reconcile call checks, a single listing of the bucket
+const MaxReconcilePageSize = 1000
+
+// Reconcile checks one page of up to limit objects after the key startAfter, an empty
+// one starts at the beginning. It reports the orphaned objects, and removes them through
+// the cleaner when remove is set. The report points to the next page, if there is one.
+func (r *AttachmentReconciler) Reconcile(remove bool, startAfter string, limit int, ctx context.Context) (*domain.AttachmentReconcileReport, error) {
+	report := domain.AttachmentReconcileReport{Removed: remove}
+	linkKeys := make(map[string]map[string]bool)
+	cutoff := time.Now().Add(-orphanGracePeriod)
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(r.bucket),
+		Prefix:  aws.String("links/"),
+		MaxKeys: aws.Int32(int32(min(max(limit, 1), MaxReconcilePageSize))),
+	}
+	if startAfter != "" {
+		input.StartAfter = aws.String(startAfter)
+	}
+
+	page, err := r.s3.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, object := range page.Contents {
+		key := aws.ToString(object.Key)
+		report.Checked++
+
+		if aws.ToTime(object.LastModified).After(cutoff) {
+			continue
+		}
+
+		// Only links/{id}/{upload}/{name} is written by the service, other keys like folder markers are left alone
+		parts := strings.SplitN(key, "/", 4)
+		if len(parts) != 4 || parts[1] == "" || parts[2] == "" || parts[3] == "" {
+			continue
+		}
+
+		linkId := parts[1]
+		keys, ok := linkKeys[linkId]
+		if !ok {
+			keys, err = r.attachedKeys(linkId, ctx)
+			if err != nil {
+				// The objects of the link are kept until a later run can look it up
+				utils.Logger.Error("Error while fetching the link of attachments", zap.String("linkID", linkId), zap.Error(err))
+			}
+
+			linkKeys[linkId] = keys
+		}
+
+		if keys == nil || keys[key] {
+			continue
+		}
+
+		report.Orphaned = append(report.Orphaned, key)
+		if !remove {
+			continue
+		}
+
+		if err := r.cleaner.Remove(key, ctx); err != nil {
+			utils.Logger.Error("Error while removing orphaned attachment", zap.String("key", key), zap.Error(err))
+			report.Failed = append(report.Failed, key)
+		}
+	}
+
+	if aws.ToBool(page.IsTruncated) && len(page.Contents) > 0 {
+		next := aws.ToString(page.Contents[len(page.Contents)-1].Key)
+		report.NextStartAfter = &next
+	}
+
+	return &report, nil
+}
+
+// reconcileAll goes through every page of the bucket
+func (r *AttachmentReconciler) reconcileAll(remove bool, ctx context.Context) (checked int, orphaned []string, err error) {
+	startAfter := ""
+	for {
+		report, err := r.Reconcile(remove, startAfter, MaxReconcilePageSize, ctx)
+		if err != nil {
+			return checked, orphaned, err
+		}
+
+		checked += report.Checked
+		orphaned = append(orphaned, report.Orphaned...)
+
+		if report.NextStartAfter == nil {
+			return checked, orphaned, nil
+		}
+
+		startAfter = *report.NextStartAfter
+	}
+}
+
+func (r *AttachmentReconciler) attachedKeys(linkId string, ctx context.Context) (map[string]bool, error) {
+	keys := make(map[string]bool)
+
+	link, err := r.repo.Get(linkId, ctx)
+	if err == ErrLinkNotFound {
+		return keys, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range link.Attachments {
+		keys[attachment.Key] = true
+	}
+
+	return keys, nil
+}
+
+// Close stops the background reconciliation, if it was started.
+func (r *AttachmentReconciler) Close() {
+	r.mu.Lock()
+	running := r.running
+	r.mu.Unlock()
+
+	if !running {
+		return
+	}
+
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+
+	<-r.done
+}
+
+// RunEvery reconciles in the background every interval until Close is called. It starts only once.
+func (r *AttachmentReconciler) RunEvery(interval time.Duration, remove bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return
+	}
+
+	r.running = true
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				checked, orphaned, err := r.reconcileAll(remove, context.Background())
+				if err != nil {
+					utils.Logger.Error("Error while reconciling attachments", zap.Error(err))
+					continue
+				}
+
+				utils.Logger.Info("Attachments reconciled", zap.Int("checked", checked), zap.Strings("orphaned", orphaned), zap.Bool("removed", remove))
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+func NewAttachmentReconciler(repo LinkRepository, s3Client *s3.Client, bucket string, cleaner *AttachmentCleaner) *AttachmentReconciler {
+	return &AttachmentReconciler{
+		repo:    repo,
+		s3:      s3Client,
+		bucket:  bucket,
+		cleaner: cleaner,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/google/uuid"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// The browser has this long to post the file after it got the upload form
+const attachmentUploadExpiry = time.Minute * 15
+
+const maxAttachments = 20
+
+// S3 metadata must be ASCII, so the original file name is stored escaped
+const fileNameMetadata = "filename"
+
+// CreateAttachmentUpload returns a presigned POST form, so the browser uploads the
+// file straight to S3. The policy of the form limits the size and pins the content type.
+func (s *LinkService) CreateAttachmentUpload(id string, uploadDTO *domain.CreateAttachmentUploadDTO, ctx context.Context) (*domain.AttachmentUpload, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	link, appErr := s.getLinkByID(id, ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr := s.access.AuthorizeLink(link, ActionEditLink, ctx); appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr := checkAttachmentsLimit(link); appErr != nil {
+		return nil, appErr
+	}
+
+	maxSize := s.maxAttachmentSize(ctx)
+	if uploadDTO.Size > maxSize {
+		logger.Debugf("File of %d bytes is too large", uploadDTO.Size)
+		return nil, errs.NewPayloadTooLargeError(fmt.Sprintf("File is larger than %d bytes", maxSize))
+	}
+
+	contentType, _, err := mime.ParseMediaType(uploadDTO.ContentType)
+	if err != nil {
+		return nil, errs.NewBadRequestError("Invalid content type")
+	}
+
+	if appErr := s.attachments.checkContentType(contentType); appErr != nil {
+		logger.Debugf("Content type %s is not allowed", contentType)
+		return nil, appErr
+	}
+
+	uploadId := uuid.NewString()
+	fileName := url.QueryEscape(uploadDTO.FileName)
+
+	request, err := s.presigner.PresignPostObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.s3Options.Bucket),
+		Key:    aws.String(attachmentKey(link.ID, uploadId, uploadDTO.FileName)),
+	}, func(options *s3.PresignPostOptions) {
+		options.Expires = attachmentUploadExpiry
+		options.Conditions = []interface{}{
+			[]interface{}{"content-length-range", 1, maxSize},
+			map[string]string{"Content-Type": contentType},
+			map[string]string{"x-amz-meta-" + fileNameMetadata: fileName},
+		}
+	})
+	if err != nil {
+		logger.Debug("Error while presigning the upload", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while creating upload")
+	}
+
+	fields := request.Values
+	fields["Content-Type"] = contentType
+	fields["x-amz-meta-"+fileNameMetadata] = fileName
+
+	logger.Debug("Attachment upload created", zap.String("uploadID", uploadId))
+	return &domain.AttachmentUpload{
+		ID:        uploadId,
+		Url:       request.URL,
+		Fields:    fields,
+		ExpiresAt: time.Now().Add(attachmentUploadExpiry),
+	}, nil
+}
+
+// CompleteAttachmentUpload adds a file the browser uploaded with CreateAttachmentUpload.
+// The object is checked again, as the options may have changed since the form was made,
+// and its content must match the declared type. Rejected objects are deleted.
+func (s *LinkService) CompleteAttachmentUpload(id string, uploadId string, ctx context.Context) (*domain.Link, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	link, appErr := s.getLinkByID(id, ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr := s.access.AuthorizeLink(link, ActionEditLink, ctx); appErr != nil {
+		return nil, appErr
+	}
+
+	if _, err := uuid.Parse(uploadId); err != nil {
+		return nil, errs.NewNotFoundError("Upload not found")
+	}
+
+	// Completing the same upload twice must not add it twice
+	if attachmentIndex(link.Attachments, uploadId) >= 0 {
+		return link, nil
+	}
+
+	if appErr := checkAttachmentsLimit(link); appErr != nil {
+		return nil, appErr
+	}
+
+	objects, err := s.s3.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.s3Options.Bucket),
+		Prefix:  aws.String(attachmentUploadPrefix(link.ID, uploadId)),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		logger.Debug("Error while listing uploaded objects", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while completing upload")
+	}
+
+	if len(objects.Contents) == 0 {
+		logger.Debug("Uploaded object not found")
+		return nil, errs.NewNotFoundError("Upload not found")
+	}
+
+	key := aws.ToString(objects.Contents[0].Key)
+
+	head, err := s.s3.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(s.s3Options.Bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		logger.Debug("Error while fetching the uploaded object", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while completing upload")
+	}
+
+	size := aws.ToInt64(head.ContentLength)
+	contentType := aws.ToString(head.ContentType)
+
+	appErr = s.checkUploadedObject(key, size, contentType, ctx)
+	if appErr != nil {
+		s.deleteObject(key, ctx)
+		return nil, appErr
+	}
+
+	fileName, err := url.QueryUnescape(head.Metadata[fileNameMetadata])
+	if err != nil || fileName == "" {
+		fileName = path.Base(key)
+	}
+
+	attachment := domain.Attachment{
+		ID:           uploadId,
+		Key:          key,
+		FileName:     fileName,
+		Size:         size,
+		ContentType:  contentType,
+		Checksum:     objectChecksum(head),
+		DateUploaded: aws.ToTime(head.LastModified),
+	}
+
+	return s.attachToLink(link, &attachment, false, ctx)
+}
+
+func (s *LinkService) checkUploadedObject(key string, size int64, contentType string, ctx context.Context) *errs.AppError {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if maxSize := s.maxAttachmentSize(ctx); size > maxSize {
+		logger.Debugf("Uploaded object of %d bytes is too large", size)
+		return errs.NewPayloadTooLargeError(fmt.Sprintf("File is larger than %d bytes", maxSize))
+	}
+
+	object, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.s3Options.Bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(fmt.Sprintf("bytes=0-%d", sniffedBytes-1)),
+	})
+	if err != nil {
+		logger.Debug("Error while fetching the uploaded object", zap.Error(err))
+		return errs.NewUnexpectedError("Error while completing upload")
+	}
+	defer object.Body.Close()
+
+	sniffed, _, err := sniffContentType(object.Body)
+	if err != nil {
+		logger.Debug("Error while reading the uploaded object", zap.Error(err))
+		return errs.NewUnexpectedError("Error while completing upload")
+	}
+
+	if appErr := s.attachments.checkContentType(mediaType(sniffed.String())); appErr != nil {
+		logger.Debugf("Content type %s is not allowed", sniffed)
+		return appErr
+	}
+
+	if !matchesDeclaredType(sniffed, mediaType(contentType)) {
+		logger.Debugf("Uploaded object is %s, but was declared as %s", sniffed, contentType)
+		return errs.NewUnsupportedMediaTypeError("File content doesn't match its content type")
+	}
+
+	return nil
+}
+
+// deleteObject removes an object that won't be used. Failures are only logged.
+func (s *LinkService) deleteObject(key string, ctx context.Context) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	_, err := s.s3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.s3Options.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		logger.Warn("Error while deleting the object", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// attachToLink adds an uploaded attachment to the link. With replace it becomes the
+// only attachment and the link is switched to the file mode, as the single file
+// uploads always did. Objects of replaced attachments are removed, a failure there
+// is left to the reconciler.
+func (s *LinkService) attachToLink(link *domain.Link, attachment *domain.Attachment, replace bool, ctx context.Context) (*domain.Link, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+	previous := link.Attachments
+
+	logger.Debugf("Attaching the file to the link. Key: %s", attachment.Key)
+
+	update := LinkUpdate{Owner: ownerOf(link), AddAttachment: attachment, DateUpdated: time.Now()}
+	if replace {
+		mode := domain.FileMode
+		update = LinkUpdate{Owner: ownerOf(link), Mode: &mode, Attachments: &[]domain.Attachment{*attachment}, DateUpdated: time.Now()}
+	}
+
+	link, err := s.repo.Update(link.ID, &update, ctx)
+	if err != nil {
+		logger.Debug("Error while updating the link", zap.Error(err))
+		return nil, linkRepositoryError(err, "Error while updating link")
+	}
+
+	if replace {
+		for i := range previous {
+			s.removeAttachment(&previous[i], ctx)
+		}
+	}
+
+	s.fillLinkFields(link)
+	s.fillUniqueVisitors(ctx, link)
+
+	logger.Debug("Link updated", zap.Any("link", link))
+	return link, nil
+}
+
+// DeleteAttachment removes one attachment of the link together with its object.
+func (s *LinkService) DeleteAttachment(id string, attachmentId string, ctx context.Context) (*domain.Link, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	link, appErr := s.getLinkByID(id, ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr := s.access.AuthorizeLink(link, ActionEditLink, ctx); appErr != nil {
+		return nil, appErr
+	}
+
+	index := attachmentIndex(link.Attachments, attachmentId)
+	if index < 0 {
+		logger.Debugf("Attachment %s not found", attachmentId)
+		return nil, errs.NewNotFoundError("Attachment not found")
+	}
+
+	attachment := link.Attachments[index]
+
+	link, err := s.repo.Update(id, &LinkUpdate{Owner: ownerOf(link), RemoveAttachment: &attachmentId, DateUpdated: time.Now()}, ctx)
+	if err != nil {
+		logger.Debug("Error while updating the link", zap.Error(err))
+		return nil, linkRepositoryError(err, "Error while updating link")
+	}
+
+	s.removeAttachment(&attachment, ctx)
+
+	s.fillLinkFields(link)
+	s.fillUniqueVisitors(ctx, link)
+
+	logger.Debug("Attachment deleted", zap.Any("attachment", attachment))
+	return link, nil
+}
+
+// checkAttachmentsLimit is checked before an upload, replacing uploads don't add to the count.
+func checkAttachmentsLimit(link *domain.Link) *errs.AppError {
+	if len(link.Attachments) >= maxAttachments {
+		return errs.NewBadRequestError(fmt.Sprintf("Link can't have more than %d attachments", maxAttachments))
+	}
+
+	return nil
+}
+
+func (s *LinkService) removeAttachment(attachment *domain.Attachment, ctx context.Context) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if err := s.cleaner.Remove(attachment.Key, ctx); err != nil {
+		logger.Warn("Error while removing the attachment", zap.String("key", attachment.Key), zap.Error(err))
+	}
+}
+
+// S3 returns the SHA-256 only when the client sent it, and only a full object one is the hash of the file
+func objectChecksum(head *s3.HeadObjectOutput) string {
+	if head.ChecksumSHA256 == nil || head.ChecksumType != types.ChecksumTypeFullObject {
+		return ""
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(*head.ChecksumSHA256)
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(sum)
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// API keys look like lsk_<key id>_<secret>. The part before the secret is
+// the visible prefix, it also lets a key be looked up without scanning the table.
+const apiKeyPrefix = "lsk_"
+
+// tokens is nil when JWTs aren't accepted.
+type AuthService struct {
+	users   UserRepository
+	apiKeys ApiKeyRepository
+	tokens  *TokenVerifier
+}
+
+// Authenticate resolves an API key or a JWT to the ID of its user.
+func (s *AuthService) Authenticate(key string, ctx context.Context) (string, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if !strings.HasPrefix(key, apiKeyPrefix) && s.tokens != nil {
+		userId, err := s.tokens.Verify(key)
+		if err != nil {
+			logger.Debug("Invalid token", zap.Error(err))
+			return "", errs.NewUnauthorizedError("Invalid token")
+		}
+
+		return userId, nil
+	}
+
+	keyId, ok := parseApiKey(key)
+	if !ok {
+		logger.Debug("Malformed API key")
+		return "", errs.NewUnauthorizedError("Invalid API key")
+	}
+
+	apiKey, err := s.apiKeys.Get(keyId, ctx)
+	if err == ErrApiKeyNotFound {
+		logger.Debug("API key not found")
+		return "", errs.NewUnauthorizedError("Invalid API key")
+	}
+
+	if err != nil {
+		logger.Debug("Error while fetching API key", zap.Error(err))
+		return "", errs.NewUnexpectedError("Error while checking API key")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashApiKey(key)), []byte(apiKey.KeyHash)) != 1 {
+		logger.Debug("API key hash mismatch")
+		return "", errs.NewUnauthorizedError("Invalid API key")
+	}
+
+	if apiKey.RevokedAt != nil {
+		logger.Debug("API key is revoked")
+		return "", errs.NewUnauthorizedError("API key is revoked")
+	}
+
+	return apiKey.UserId, nil
+}
+
+// CreateUser creates a user together with its first API key.
+func (s *AuthService) CreateUser(userDTO *domain.CreateUserDTO, ctx context.Context) (*domain.CreatedUser, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	userId := userDTO.ID
+	if userId == "" {
+		userId = xid.New().String()
+	}
+
+	user := domain.User{
+		ID:          userId,
+		Name:        userDTO.Name,
+		Plan:        userDTO.Plan,
+		DateCreated: time.Now(),
+	}
+
+	if err := s.users.Put(&user, ctx); err != nil {
+		if err == ErrUserAlreadyExists {
+			logger.Debug("User is already exists")
+			return nil, errs.NewBadRequestError("User is already exists")
+		}
+
+		logger.Debug("Error while creating the user", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while creating user")
+	}
+
+	apiKey, appErr := s.createApiKey(user.ID, "default", ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	logger.Debug("User created", zap.String("userID", user.ID))
+	return &domain.CreatedUser{User: &user, ApiKey: apiKey}, nil
+}
+
+func (s *AuthService) GetApiKeys(ctx context.Context) ([]domain.ApiKey, *errs.AppError) {
+	userId := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	keys, err := s.apiKeys.ListByUser(userId, ctx)
+	if err != nil {
+		logger.Debug("Error while fetching API keys", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while fetching API keys")
+	}
+
+	return keys, nil
+}
+
+func (s *AuthService) CreateApiKey(keyDTO *domain.CreateApiKeyDTO, ctx context.Context) (*domain.ApiKey, *errs.AppError) {
+	userId := ctx.Value("UserID").(string)
+
+	return s.createApiKey(userId, keyDTO.Name, ctx)
+}
+
+func (s *AuthService) RevokeApiKey(id string, ctx context.Context) (*domain.ApiKey, *errs.AppError) {
+	userId := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	apiKey, err := s.apiKeys.Revoke(id, userId, time.Now(), ctx)
+	if err == ErrApiKeyNotFound {
+		logger.Debug("API key not found")
+		return nil, errs.NewNotFoundError("API key not found")
+	}
+
+	if err != nil {
+		logger.Debug("Error while revoking API key", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while revoking API key")
+	}
+
+	logger.Debug("API key revoked", zap.String("keyID", id))
+	return apiKey, nil
+}
+
+// createApiKey returns the key with its plain value, which is never available afterwards.
+func (s *AuthService) createApiKey(userId string, name string, ctx context.Context) (*domain.ApiKey, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	keyId := utils.RandomToken(8)
+	key := apiKeyPrefix + keyId + "_" + utils.RandomToken(24)
+
+	apiKey := domain.ApiKey{
+		ID:          keyId,
+		UserId:      userId,
+		Name:        name,
+		Prefix:      apiKeyPrefix + keyId,
+		KeyHash:     hashApiKey(key),
+		DateCreated: time.Now(),
+	}
+
+	if err := s.apiKeys.Put(&apiKey, ctx); err != nil {
+		logger.Debug("Error while creating API key", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while creating API key")
+	}
+
+	apiKey.Key = key
+	return &apiKey, nil
+}
+
+func parseApiKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyPrefix)
+	if !ok {
+		return "", false
+	}
+
+	keyId, secret, ok := strings.Cut(rest, "_")
+	if !ok || keyId == "" || secret == "" {
+		return "", false
+	}
+
+	return keyId, true
+}
+
+// Keys are random, so a plain SHA-256 is enough to store them
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func NewAuthService(users UserRepository, apiKeys ApiKeyRepository, tokens *TokenVerifier) AuthService {
+	return AuthService{users: users, apiKeys: apiKeys, tokens: tokens}
+}
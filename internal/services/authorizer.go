@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"go.uber.org/zap"
+)
+
+type Action string
+
+const (
+	ActionViewLink      Action = "link:view"
+	ActionCreateLink    Action = "link:create"
+	ActionEditLink      Action = "link:edit"
+	ActionDeleteLink    Action = "link:delete"
+	ActionViewMembers   Action = "workspace:view"
+	ActionManageMembers Action = "workspace:members"
+)
+
+// Actions every workspace role is allowed to do. The creator of a personal link can do everything with it.
+var roleActions = map[domain.WorkspaceRole]map[Action]bool{
+	domain.RoleOwner: {
+		ActionViewLink:      true,
+		ActionCreateLink:    true,
+		ActionEditLink:      true,
+		ActionDeleteLink:    true,
+		ActionViewMembers:   true,
+		ActionManageMembers: true,
+	},
+	domain.RoleEditor: {
+		ActionViewLink:    true,
+		ActionCreateLink:  true,
+		ActionEditLink:    true,
+		ActionDeleteLink:  true,
+		ActionViewMembers: true,
+	},
+	domain.RoleViewer: {
+		ActionViewLink:    true,
+		ActionViewMembers: true,
+	},
+}
+
+// Authorizer decides whether the user of the request can do an action on a link or a workspace.
+type Authorizer struct {
+	workspaces WorkspaceRepository
+}
+
+func (a *Authorizer) AuthorizeLink(link *domain.Link, action Action, ctx context.Context) *errs.AppError {
+	userId, _ := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if link.WorkspaceId != "" {
+		return a.AuthorizeWorkspace(link.WorkspaceId, action, ctx)
+	}
+
+	if userId == "" || link.UserId != userId {
+		logger.Debugf("User is not a owner of the link. Action: %s", action)
+		return errs.NewForbiddenError("You don't have access to this link")
+	}
+
+	return nil
+}
+
+// AuthorizeWorkspace checks that the role of the user in the workspace allows the action.
+func (a *Authorizer) AuthorizeWorkspace(workspaceId string, action Action, ctx context.Context) *errs.AppError {
+	userId, _ := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if userId == "" {
+		return errs.NewForbiddenError("You don't have access to this workspace")
+	}
+
+	member, err := a.workspaces.GetMember(workspaceId, userId, ctx)
+	if err == ErrWorkspaceMemberNotFound {
+		logger.Debugf("User is not a member of the workspace %s", workspaceId)
+		return errs.NewForbiddenError("You don't have access to this workspace")
+	}
+
+	if err != nil {
+		logger.Debug("Error while fetching workspace member", zap.Error(err))
+		return errs.NewUnexpectedError("Error while checking access")
+	}
+
+	if !roleActions[member.Role][action] {
+		logger.Debugf("Role %s doesn't allow %s", member.Role, action)
+		return errs.NewForbiddenError("Your role doesn't allow this action")
+	}
+
+	return nil
+}
+
+func NewAuthorizer(workspaces WorkspaceRepository) *Authorizer {
+	return &Authorizer{workspaces: workspaces}
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// Link preview fetchers of messengers and social networks, search engine
+// crawlers, mail security scanners and generic HTTP clients
+var defaultBotPatterns = []string{
+	`(?i)slackbot|slack-imgproxy`,
+	`(?i)facebookexternalhit|facebot|meta-externalagent`,
+	`(?i)twitterbot`,
+	`(?i)whatsapp`,
+	`(?i)telegrambot`,
+	`(?i)discordbot`,
+	`(?i)linkedinbot`,
+	`(?i)skypeuripreview`,
+	`(?i)applebot`,
+	`(?i)redditbot|pinterestbot|pinterest/0\.|vkshare|embedly|iframely|mastodon`,
+	`(?i)barracuda|proofpoint|mimecast|urlscan|zscaler|safelinks`,
+	`(?i)headless|phantomjs|selenium|puppeteer|playwright`,
+	`(?i)curl/|wget/|python-requests|python-urllib|go-http-client|okhttp|java/|libwww|httpclient`,
+	// Crawlers put the bot into a product token, e.g. Googlebot/2.1 or DuckDuckBot-Https/1.1,
+	// a bare bot suffix would also match phone models like CUBOT
+	`(?i)[a-z]bot[/;)-]|\bbot\b|crawler|spider|crawl|preview|fetcher|scanner`,
+}
+
+// BotRules extends the default patterns. Allow patterns win over bot patterns,
+// so a false positive can be fixed without touching the defaults.
+type BotRules struct {
+	UserAgentPatterns      []string `json:"userAgentPatterns"`
+	AllowUserAgentPatterns []string `json:"allowUserAgentPatterns"`
+}
+
+type BotDetector struct {
+	patterns      []*regexp.Regexp
+	allowPatterns []*regexp.Regexp
+}
+
+// IsBot classifies a request by its method and User-Agent header.
+// HEAD requests and requests without a user agent are never made by people clicking a link.
+func (d *BotDetector) IsBot(method string, userAgent string) bool {
+	if method == http.MethodHead || userAgent == "" {
+		return true
+	}
+
+	for _, pattern := range d.allowPatterns {
+		if pattern.MatchString(userAgent) {
+			return false
+		}
+	}
+
+	for _, pattern := range d.patterns {
+		if pattern.MatchString(userAgent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LoadBotRules reads the rules from a JSON file.
+func LoadBotRules(path string) (*BotRules, error) {
+	var rules BotRules
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid bot rules file %s: %w", path, err)
+	}
+
+	return &rules, nil
+}
+
+func NewBotDetector(rules *BotRules) (*BotDetector, error) {
+	patterns := defaultBotPatterns
+	var allowPatterns []string
+
+	if rules != nil {
+		patterns = append(append([]string{}, patterns...), rules.UserAgentPatterns...)
+		allowPatterns = rules.AllowUserAgentPatterns
+	}
+
+	detector := &BotDetector{}
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bot pattern %q: %w", pattern, err)
+		}
+
+		detector.patterns = append(detector.patterns, re)
+	}
+
+	for _, pattern := range allowPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allow pattern %q: %w", pattern, err)
+		}
+
+		detector.allowPatterns = append(detector.allowPatterns, re)
+	}
+
+	return detector, nil
+}
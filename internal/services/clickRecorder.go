@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ClickRecorder writes click events in background workers, so the redirect
+// doesn't wait for the storage. Clicks are dropped when the queue is full.
+// Without workers every click is written right away, for runtimes like Lambda
+// that are frozen between requests and never get to drain a queue.
+type ClickRecorder struct {
+	repo  ClickRepository
+	queue chan *domain.Click
+
+	// Requests still running when the server gives up draining may record after
+	// Close, the lock keeps them from sending on the closed queue
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+}
+
+// Record queues the click and reports whether it was accepted.
+func (r *ClickRecorder) Record(click *domain.Click) bool {
+	if r.queue == nil {
+		r.put(click)
+		return true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		return false
+	}
+
+	select {
+	case r.queue <- click:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close stops accepting clicks and waits until the queued ones are written or ctx is done.
+func (r *ClickRecorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed && r.queue != nil {
+		close(r.queue)
+	}
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *ClickRecorder) run() {
+	defer r.workers.Done()
+
+	for click := range r.queue {
+		r.put(click)
+	}
+}
+
+func (r *ClickRecorder) put(click *domain.Click) {
+	if err := r.repo.Put(click, context.Background()); err != nil {
+		utils.Logger.Error("Error while saving click", zap.String("linkID", click.LinkId), zap.Error(err))
+	}
+}
+
+func NewClickRecorder(repo ClickRepository, queueSize int, workers int) *ClickRecorder {
+	recorder := &ClickRecorder{repo: repo}
+	if workers == 0 {
+		return recorder
+	}
+
+	recorder.queue = make(chan *domain.Click, queueSize)
+
+	recorder.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go recorder.run()
+	}
+
+	return recorder
+}
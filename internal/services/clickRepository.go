@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+// clickIdTimeFormat has a fixed width, so click IDs sort in time order.
+const clickIdTimeFormat = "20060102T150405.000000000Z"
+
+// ClickRepository stores click events keyed by link and time.
+type ClickRepository interface {
+	Put(click *domain.Click, ctx context.Context) error
+	// EachByLink calls fn with every click of the link in [from, to) ordered by time.
+	// The clicks are read page by page, so a busy link isn't held in memory at once.
+	EachByLink(linkId string, from time.Time, to time.Time, fn func(click *domain.Click), ctx context.Context) error
+}
+
+func newClickId(timestamp time.Time) string {
+	return timestamp.UTC().Format(clickIdTimeFormat) + "-" + xid.New().String()
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/guregu/dynamo/v2"
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+type DynamoDBClickRepository struct {
+	clicksTable dynamo.Table
+}
+
+func (r *DynamoDBClickRepository) Put(click *domain.Click, ctx context.Context) error {
+	return r.clicksTable.Put(click).Run(ctx)
+}
+
+func (r *DynamoDBClickRepository) EachByLink(linkId string, from time.Time, to time.Time, fn func(click *domain.Click), ctx context.Context) error {
+	// Click IDs start with the time, so the bounds only need the time prefix
+	fromKey := from.UTC().Format(clickIdTimeFormat)
+	toKey := to.UTC().Format(clickIdTimeFormat)
+
+	iter := r.clicksTable.Get("LinkId", linkId).Range("ClickId", dynamo.Between, fromKey, toKey).Iter()
+	for {
+		// Attributes missing in the next item would otherwise keep the previous values
+		var click domain.Click
+		if !iter.Next(ctx, &click) {
+			break
+		}
+
+		fn(&click)
+	}
+
+	return iter.Err()
+}
+
+func NewDynamoDBClickRepository(db *dynamo.DB) *DynamoDBClickRepository {
+	table := GetOrCreateTable(db, "Clicks", domain.Click{})
+
+	return &DynamoDBClickRepository{clicksTable: table}
+}
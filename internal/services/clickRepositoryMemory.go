@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+// MemoryClickRepository keeps click events in process memory.
+type MemoryClickRepository struct {
+	mu     sync.RWMutex
+	clicks map[string][]domain.Click
+}
+
+func (r *MemoryClickRepository) Put(click *domain.Click, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clicks[click.LinkId] = append(r.clicks[click.LinkId], *click)
+	return nil
+}
+
+func (r *MemoryClickRepository) EachByLink(linkId string, from time.Time, to time.Time, fn func(click *domain.Click), ctx context.Context) error {
+	r.mu.RLock()
+	var clicks []domain.Click
+	for _, click := range r.clicks[linkId] {
+		if !click.Timestamp.Before(from) && click.Timestamp.Before(to) {
+			clicks = append(clicks, click)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(clicks, func(i, j int) bool {
+		return clicks[i].ClickId < clicks[j].ClickId
+	})
+
+	for i := range clicks {
+		fn(&clicks[i])
+	}
+
+	return nil
+}
+
+func NewMemoryClickRepository() *MemoryClickRepository {
+	return &MemoryClickRepository{clicks: make(map[string][]domain.Click)}
+}
@@ -0,0 +1,5 @@
+// Package services holds the business logic of the link shortener and the
+// storages behind it. Every repository has a DynamoDB implementation and a
+// Memory one that keeps the data in process memory, the Memory ones are meant
+// for tests and local runs.
+package services
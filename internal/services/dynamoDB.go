@@ -2,7 +2,6 @@ package services
 
 import (
 	"context"
-	"os"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
@@ -10,11 +9,9 @@ import (
 	"github.com/the-redx/link-shortener/pkg/utils"
 )
 
-func NewDynamoDBService() *dynamo.DB {
-	environment := os.Getenv("APP_ENV")
-	dynamoEndpoint := os.Getenv("DYNAMODB_ENDPOINT")
-
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("eu-north-1"))
+// NewDynamoDBService connects to the DynamoDB of the region, or to a local one at the endpoint.
+func NewDynamoDBService(region string, endpoint string) *dynamo.DB {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(region))
 	if err != nil {
 		utils.Logger.Fatal("Error loading AWS config")
 	}
@@ -22,14 +19,14 @@ func NewDynamoDBService() *dynamo.DB {
 	utils.Logger.Debug("DynamoDB config created")
 
 	return dynamo.New(cfg, func(o *dynamodb.Options) {
-		if environment == "development" && dynamoEndpoint != "" {
-			utils.Logger.Debugf("DynamoDB base endpoint is set to %s", dynamoEndpoint)
-			o.BaseEndpoint = &dynamoEndpoint
+		if endpoint != "" {
+			utils.Logger.Debugf("DynamoDB base endpoint is set to %s", endpoint)
+			o.BaseEndpoint = &endpoint
 		}
 	})
 }
 
-func GetOrCreateTable(db *dynamo.DB, tableName string, from interface{}) dynamo.Table {
+func GetOrCreateTable(db *dynamo.DB, tableName string, from interface{}, indexes ...dynamo.Index) dynamo.Table {
 	utils.Logger.Debugf("Checking if table %s exists", tableName)
 
 	tables, err := db.ListTables().All(context.TODO())
@@ -42,13 +39,18 @@ func GetOrCreateTable(db *dynamo.DB, tableName string, from interface{}) dynamo.
 	for _, table := range tables {
 		if table == tableName {
 			utils.Logger.Debug("Table found")
-			return db.Table(tableName)
+			return upgradeTableIndexes(db.Table(tableName), indexes)
 		}
 	}
 
 	utils.Logger.Debug("Table not found. Creating...")
 
-	if err := db.CreateTable(tableName, from).Run(context.TODO()); err != nil {
+	createTable := db.CreateTable(tableName, from)
+	for _, index := range indexes {
+		createTable = createTable.Index(index)
+	}
+
+	if err := createTable.Run(context.TODO()); err != nil {
 		utils.Logger.Panic(err)
 	}
 
@@ -56,3 +58,45 @@ func GetOrCreateTable(db *dynamo.DB, tableName string, from interface{}) dynamo.
 
 	return db.Table(tableName)
 }
+
+// upgradeTableIndexes creates global secondary indexes that are missing on an existing table.
+// DynamoDB accepts only one index creation per UpdateTable call, so they are created one by one.
+func upgradeTableIndexes(table dynamo.Table, indexes []dynamo.Index) dynamo.Table {
+	if len(indexes) == 0 {
+		return table
+	}
+
+	description, err := table.Describe().Run(context.TODO())
+	if err != nil {
+		utils.Logger.Panic(err)
+	}
+
+	existing := make(map[string]bool)
+	for _, index := range description.GSI {
+		existing[index.Name] = true
+	}
+
+	for _, index := range indexes {
+		if existing[index.Name] {
+			continue
+		}
+
+		if !description.OnDemand && index.Throughput.Read == 0 && index.Throughput.Write == 0 {
+			index.Throughput = dynamo.Throughput{Read: 1, Write: 1}
+		}
+
+		utils.Logger.Debugf("Index %s not found on table %s. Creating...", index.Name, table.Name())
+
+		if _, err := table.UpdateTable().CreateIndex(index).Run(context.TODO()); err != nil {
+			utils.Logger.Panic(err)
+		}
+
+		if err := table.Wait(context.TODO()); err != nil {
+			utils.Logger.Panic(err)
+		}
+
+		utils.Logger.Debugf("Index %s created", index.Name)
+	}
+
+	return table
+}
@@ -1,15 +1,106 @@
 package services
 
 import (
-	"os"
+	"encoding/base64"
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"golang.org/x/crypto/bcrypt"
 )
 
-func createShortUrlFromID(id string) string {
-	domainName := os.Getenv("DOMAIN_NAME")
+func linkRepositoryError(err error, message string) *errs.AppError {
+	if err == ErrLinkNotFound {
+		return errs.NewNotFoundError("Link not found")
+	}
+
+	if err == ErrAttachmentNotFound {
+		return errs.NewNotFoundError("Attachment not found")
+	}
+
+	return errs.NewUnexpectedError(message)
+}
+
+func encodeLinkCursor(cursor *LinkCursor) string {
+	data, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLinkCursor(value string) (*LinkCursor, error) {
+	var cursor LinkCursor
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
+func isLinkExpired(link *domain.Link, now time.Time) bool {
+	if link.ExpiresAt != nil && !now.Before(*link.ExpiresAt) {
+		return true
+	}
+
+	return link.MaxRedirects > 0 && link.Redirects >= link.MaxRedirects
+}
+
+const maxPasswordBytes = 72
+
+// hashLinkPassword returns an empty hash for an empty password, which removes the protection.
+func hashLinkPassword(password string) (string, *errs.AppError) {
+	if password == "" {
+		return "", nil
+	}
+
+	if len(password) < 4 {
+		return "", errs.NewBadRequestError("Password must be at least 4 characters long")
+	}
+
+	// bcrypt refuses more than 72 bytes, a shorter password of multibyte characters can still exceed them
+	if len(password) > maxPasswordBytes {
+		return "", errs.NewBadRequestError("Password must be at most 72 bytes long")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errs.NewUnexpectedError("Error while hashing the password")
+	}
+
+	return string(hash), nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// attachmentKey gives every upload its own key, so files with the same name don't overwrite each other.
+func attachmentKey(linkId string, uploadId string, fileName string) string {
+	return attachmentUploadPrefix(linkId, uploadId) + sanitizeFileName(fileName)
+}
+
+func attachmentUploadPrefix(linkId string, uploadId string) string {
+	return "links/" + linkId + "/" + uploadId + "/"
+}
+
+func sanitizeFileName(fileName string) string {
+	name := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	name = strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "-"), "-.")
+
+	if len(name) > 100 {
+		name = name[len(name)-100:]
+	}
 
-	if domainName == "" {
-		return id
+	if name == "" {
+		return "file"
 	}
 
-	return domainName + "/" + id
+	return name
 }
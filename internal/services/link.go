@@ -1,102 +1,251 @@
 package services
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"mime/multipart"
+	"mime"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/guregu/dynamo/v2"
+	"github.com/google/uuid"
 	"github.com/the-redx/link-shortener/internal/domain"
 	"github.com/the-redx/link-shortener/pkg/errs"
 	"github.com/the-redx/link-shortener/pkg/utils"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// FileUpload is a file read straight from the request body.
+type FileUpload struct {
+	FileName    string
+	ContentType string
+	Body        io.Reader
+}
+
+// Password is nil when the visitor didn't submit the unlock form. RetryAfter is
+// set by the service when wrong passwords of the visitor are throttled.
+type RedirectRequest struct {
+	Bot        bool
+	Password   *string
+	ClientIP   string
+	RetryAfter time.Duration
+}
+
 type LinkService struct {
-	dynamoDB   *dynamo.DB
-	s3         *s3.Client
-	linksTable dynamo.Table
+	domainName       string
+	repo             LinkRepository
+	stats            LinkStatsRepository
+	counters         RedirectCounter
+	access           *Authorizer
+	users            UserRepository
+	passwordAttempts *PasswordAttempts
+	s3               *s3.Client
+	s3Options        S3Options
+	uploader         *manager.Uploader
+	presigner        *s3.PresignClient
+	attachments      AttachmentOptions
+	cleaner          *AttachmentCleaner
 }
 
-func (s *LinkService) GetAllLinks(ctx context.Context) (*[]domain.Link, *errs.AppError) {
-	var links []domain.Link
+func (s *LinkService) GetAllLinks(query *domain.ListLinksDTO, ctx context.Context) (*domain.LinksPage, *errs.AppError) {
+	var page domain.LinksPage
 
 	userId, ok := ctx.Value("UserID").(string)
 	if !ok {
-		return &links, nil
+		return &page, nil
 	}
 
 	logger := ctx.Value("Logger").(*zap.SugaredLogger)
 
-	if err := s.linksTable.Scan().Filter("'UserId' = ? AND 'Status' = ?", userId, "active").All(context.TODO(), &links); err != nil {
+	options := ListLinksOptions{Limit: query.Limit, Sort: query.Sort}
+	if query.Status != "all" {
+		options.Status = domain.LinkStatus(query.Status)
+	}
+
+	if query.WorkspaceId != "" {
+		if appErr := s.access.AuthorizeWorkspace(query.WorkspaceId, ActionViewLink, ctx); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeLinkCursor(query.Cursor)
+		// A cursor is valid only for the listing it was made for
+		if err != nil || cursor.WorkspaceId != query.WorkspaceId || (query.WorkspaceId == "" && cursor.UserId != userId) {
+			logger.Debug("Invalid cursor", zap.Error(err))
+			return nil, errs.NewBadRequestError("Invalid cursor")
+		}
+
+		options.Cursor = cursor
+	}
+
+	var result *LinkPage
+	var err error
+	if query.WorkspaceId != "" {
+		result, err = s.repo.ListByWorkspace(query.WorkspaceId, &options, ctx)
+	} else {
+		result, err = s.repo.ListByUser(userId, &options, ctx)
+	}
+
+	if err != nil {
 		logger.Debug("Error while fetching links", zap.Error(err))
 		return nil, errs.NewUnexpectedError("Error while fetching links")
 	}
 
-	for i := range links {
-		links[i].ShortUrl = createShortUrlFromID(links[i].ID)
+	page.Links = result.Links
+	for i := range page.Links {
+		s.fillLinkFields(&page.Links[i])
+	}
+
+	links := make([]*domain.Link, 0, len(page.Links))
+	for i := range page.Links {
+		links = append(links, &page.Links[i])
 	}
 
-	logger.Debug("Response", zap.Any("links", links))
-	return &links, nil
+	s.fillUniqueVisitors(ctx, links...)
+
+	if result.NextCursor != nil {
+		nextCursor := encodeLinkCursor(result.NextCursor)
+		page.NextCursor = &nextCursor
+	}
+
+	logger.Debug("Response", zap.Any("links", page.Links))
+	return &page, nil
 }
 
 func (s *LinkService) GetLinkByID(id string, ctx context.Context) (*domain.Link, *errs.AppError) {
-	userId, ok := ctx.Value("UserID").(string)
-	logger := ctx.Value("Logger").(*zap.SugaredLogger)
-
-	link, appErr := s.getLinkByID(id, userId, ctx)
+	link, appErr := s.getLinkByID(id, ctx)
 	if appErr != nil {
 		return nil, appErr
 	}
 
-	if !ok || userId != link.UserId {
-		logger.Debug("User is not a owner and link is not active")
-		return nil, errs.NewForbiddenError("You don't have access to this link")
+	if appErr := s.access.AuthorizeLink(link, ActionViewLink, ctx); appErr != nil {
+		return nil, appErr
 	}
 
+	s.fillUniqueVisitors(ctx, link)
+
 	return link, nil
 }
 
-func (s *LinkService) GetLinkByIDForRedirect(id string, ctx context.Context) (*domain.Link, *errs.AppError) {
+func (s *LinkService) GetLinkByIDForRedirect(id string, request *RedirectRequest, ctx context.Context) (*domain.Link, *errs.AppError) {
 	logger := ctx.Value("Logger").(*zap.SugaredLogger)
 
 	logger.Debugf("Fetching link by ID: %s", id)
 
-	var links []domain.Link
-	if err := s.linksTable.Scan().Filter("'ID' = ? AND 'Status' = ?", id, "active").All(context.TODO(), &links); err != nil {
-		logger.Debug("Error while fetching links", zap.Error(err))
-		return nil, errs.NewUnexpectedError("Error while fetching link")
-	}
-
-	if len(links) == 0 {
-		logger.Debug("Link not found. Slice length is 0")
-		return nil, errs.NewNotFoundError("Link not found")
+	link, appErr := s.getLinkByID(id, ctx)
+	if appErr != nil {
+		return nil, appErr
 	}
 
-	link := &links[0]
 	logger.Debugf("Link status: %s", link.Status)
 
+	if link.Status == domain.Expired {
+		logger.Debug("Link is expired")
+		return link, errs.NewGoneError("Link is expired")
+	}
+
 	if link.Status != domain.Active {
 		logger.Debug("Link is not active")
 		return nil, errs.NewNotFoundError("Link not found")
 	}
 
-	// Increment the Redirects counter
-	if err := s.linksTable.Update("ID", id).Range("UserId", link.UserId).Set("Redirects", link.Redirects+1).Run(context.TODO()); err != nil {
+	if isLinkExpired(link, time.Now()) {
+		return s.expireLink(link, ctx)
+	}
+
+	// Protected links are counted only once they are unlocked
+	if link.Protected {
+		if appErr := s.checkLinkPassword(link, request, ctx); appErr != nil {
+			return link, appErr
+		}
+	}
+
+	// Bots are counted apart, so they don't inflate the redirects of people
+	// and don't use up links with a redirects limit
+	if request.Bot {
+		logger.Debug("Redirect of a bot")
+
+		if err := s.counters.Increment(id, BotRedirectsCounter, ctx); err != nil {
+			logger.Debug("Error while updating the link", zap.Error(err))
+		}
+
+		return s.fillRedirectUrl(link, ctx)
+	}
+
+	// The limit must hold under concurrent clicks, so such links skip the buffered counters
+	if link.MaxRedirects > 0 {
+		err := s.repo.IncrementCounterBelow(id, RedirectsCounter, link.MaxRedirects, ctx)
+		if err == ErrCounterLimit {
+			logger.Debug("Link reached the redirects limit")
+			return s.expireLink(link, ctx)
+		}
+
+		if err != nil {
+			logger.Debug("Error while updating the link", zap.Error(err))
+			return nil, linkRepositoryError(err, "Error while fetching link")
+		}
+
+		return s.fillRedirectUrl(link, ctx)
+	}
+
+	if err := s.counters.Increment(id, RedirectsCounter, ctx); err != nil {
 		logger.Debug("Error while updating the link", zap.Error(err))
 	}
 
+	return s.fillRedirectUrl(link, ctx)
+}
+
+// fillRedirectUrl points a link in the file mode to a short-lived presigned URL
+// of its latest attachment. It's made only once the link passed its checks.
+// Without attachments the link falls back to its own URL.
+func (s *LinkService) fillRedirectUrl(link *domain.Link, ctx context.Context) (*domain.Link, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if link.Mode != domain.FileMode || len(link.Attachments) == 0 {
+		return link, nil
+	}
+
+	url, err := s.attachmentUrl(&link.Attachments[len(link.Attachments)-1], attachmentUrlExpiry, ctx)
+	if err != nil {
+		logger.Debug("Error while presigning the attachment URL", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while fetching link")
+	}
+
+	link.Url = url
 	return link, nil
 }
 
+// attachmentUrl presigns a download of the attachment, unless the objects are served by a CDN
+func (s *LinkService) attachmentUrl(attachment *domain.Attachment, expiry time.Duration, ctx context.Context) (string, error) {
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName})
+
+	if s.s3Options.PublicUrl != "" {
+		// Spaces are sent as %20, S3 doesn't read + as a space
+		query := strings.ReplaceAll(url.Values{"response-content-disposition": {disposition}}.Encode(), "+", "%20")
+		return publicObjectUrl(s.s3Options.PublicUrl, attachment.Key) + "?" + query, nil
+	}
+
+	request, err := s.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(s.s3Options.Bucket),
+		Key:                        aws.String(attachment.Key),
+		ResponseContentDisposition: aws.String(disposition),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+
+	return request.URL, nil
+}
+
 func (s *LinkService) CreateLink(linkDTO *domain.CreateLinkDTO, ctx context.Context) (*domain.Link, *errs.AppError) {
 	var link domain.Link
 
@@ -119,25 +268,52 @@ func (s *LinkService) CreateLink(linkDTO *domain.CreateLinkDTO, ctx context.Cont
 
 	utils.Logger.Debug(zap.String("linkID", linkID))
 
-	if err := s.linksTable.Get("ID", linkID).One(context.TODO(), &link); err == nil {
-		logger.Debug("Item is already exists")
-		return nil, errs.NewUnexpectedError("Item is already exists")
+	if linkDTO.WorkspaceId != "" {
+		if appErr := s.access.AuthorizeWorkspace(linkDTO.WorkspaceId, ActionCreateLink, ctx); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	if linkDTO.ExpiresAt != nil && !linkDTO.ExpiresAt.After(time.Now()) {
+		logger.Debug("Expiration date is in the past")
+		return nil, errs.NewBadRequestError("Expiration date must be in the future")
+	}
+
+	passwordHash, appErr := hashLinkPassword(linkDTO.Password)
+	if appErr != nil {
+		return nil, appErr
 	}
 
 	link = domain.Link{
-		ID:          linkID,
-		Name:        linkDTO.Name,
-		UserId:      userId,
-		ShortUrl:    createShortUrlFromID(linkID),
-		Url:         linkDTO.Url,
-		Status:      domain.Active,
-		DateCreated: time.Now(),
-		DateUpdated: time.Now(),
+		ID:           linkID,
+		Name:         linkDTO.Name,
+		UserId:       userId,
+		WorkspaceId:  linkDTO.WorkspaceId,
+		ShortUrl:     s.shortUrl(linkID),
+		Url:          linkDTO.Url,
+		Status:       domain.Active,
+		ExpiresAt:    linkDTO.ExpiresAt,
+		MaxRedirects: linkDTO.MaxRedirects,
+		ExpiredUrl:   linkDTO.ExpiredUrl,
+		PasswordHash: passwordHash,
+		Protected:    passwordHash != "",
+		Mode:         linkDTO.Mode,
+		DateCreated:  time.Now(),
+		DateUpdated:  time.Now(),
+	}
+
+	if link.Mode == "" {
+		link.Mode = domain.RedirectMode
 	}
 
 	logger.Debug("Link to create", zap.Any("link", link))
 
-	if err := s.linksTable.Put(link).Run(context.TODO()); err != nil {
+	if err := s.repo.Put(&link, ctx); err != nil {
+		if err == ErrLinkAlreadyExists {
+			logger.Debug("Item is already exists")
+			return nil, errs.NewUnexpectedError("Item is already exists")
+		}
+
 		logger.Debug("Error while creating the link", zap.Error(err))
 		return nil, errs.NewUnexpectedError("Error while creating link")
 	}
@@ -147,17 +323,21 @@ func (s *LinkService) CreateLink(linkDTO *domain.CreateLinkDTO, ctx context.Cont
 }
 
 func (s *LinkService) UpdateLinkByID(id string, linkDTO *domain.UpdateLinkDTO, ctx context.Context) (*domain.Link, *errs.AppError) {
-	userId := ctx.Value("UserID").(string)
 	logger := ctx.Value("Logger").(*zap.SugaredLogger)
 
-	link, appErr := s.getLinkByID(id, userId, ctx)
+	link, appErr := s.getLinkByID(id, ctx)
 	if appErr != nil {
 		return nil, appErr
 	}
 
-	if link.UserId != userId {
-		logger.Debug("User is not a owner")
-		return nil, errs.NewForbiddenError("You don't have access to this link")
+	if appErr := s.access.AuthorizeLink(link, ActionEditLink, ctx); appErr != nil {
+		return nil, appErr
+	}
+
+	// A zero expiresAt removes the expiration, any other one must be in the future like on create
+	if linkDTO.ExpiresAt != nil && !linkDTO.ExpiresAt.IsZero() && !linkDTO.ExpiresAt.After(time.Now()) {
+		logger.Debug("Expiration date is in the past")
+		return nil, errs.NewBadRequestError("Expiration date must be in the future")
 	}
 
 	name := linkDTO.Name
@@ -170,122 +350,266 @@ func (s *LinkService) UpdateLinkByID(id string, linkDTO *domain.UpdateLinkDTO, c
 		status = link.Status
 	}
 
-	logger.Debug("Link to update", zap.Any("link", link))
+	update := LinkUpdate{
+		Owner:        ownerOf(link),
+		Name:         &name,
+		Status:       &status,
+		ExpiresAt:    linkDTO.ExpiresAt,
+		MaxRedirects: linkDTO.MaxRedirects,
+		ExpiredUrl:   linkDTO.ExpiredUrl,
+		DateUpdated:  time.Now(),
+	}
 
-	if err := s.linksTable.Update("ID", id).Range("UserId", userId).Set("Name", name).Set("Status", status).Set("DateUpdated", time.Now().UTC().Format(time.RFC3339)).Run(context.TODO()); err != nil {
-		logger.Debug("Error while updating the link", zap.Error(err))
-		return nil, errs.NewUnexpectedError("Error while updating link")
+	if linkDTO.Password != nil {
+		passwordHash, appErr := hashLinkPassword(*linkDTO.Password)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		update.PasswordHash = &passwordHash
 	}
 
-	logger.Debug("Link updated", zap.Any("link", link))
+	if linkDTO.Mode != "" {
+		// A file link without files would silently redirect to its URL
+		if linkDTO.Mode == domain.FileMode && len(link.Attachments) == 0 {
+			logger.Debug("Link has no attachments")
+			return nil, errs.NewBadRequestError("Link has no attachments")
+		}
 
-	link, appErr = s.getLinkByID(id, userId, ctx)
-	if appErr != nil {
-		return nil, appErr
+		update.Mode = &linkDTO.Mode
+	}
+
+	logger.Debug("Link to update", zap.Any("link", link))
+
+	link, err := s.repo.Update(id, &update, ctx)
+	if err != nil {
+		logger.Debug("Error while updating the link", zap.Error(err))
+		return nil, linkRepositoryError(err, "Error while updating link")
 	}
 
+	s.fillLinkFields(link)
+	s.fillUniqueVisitors(ctx, link)
+
+	logger.Debug("Link updated", zap.Any("link", link))
 	return link, nil
 }
 
-func (s *LinkService) AttachFileToLinkByID(id string, file *multipart.File, headers *multipart.FileHeader, ctx context.Context) (*domain.Link, *errs.AppError) {
-	userId := ctx.Value("UserID").(string)
+// AttachFileToLinkByID streams the file to S3 while its size and checksum are counted, so it's never held in memory.
+// The type of the file is sniffed from its first bytes, and the upload is aborted once it's over the size limit.
+// The file is added to the attachments of the link, or replaces all of them with replace.
+func (s *LinkService) AttachFileToLinkByID(id string, upload *FileUpload, replace bool, ctx context.Context) (*domain.Link, *errs.AppError) {
 	logger := ctx.Value("Logger").(*zap.SugaredLogger)
 
-	link, appErr := s.getLinkByID(id, userId, ctx)
+	link, appErr := s.getLinkByID(id, ctx)
 	if appErr != nil {
 		return nil, appErr
 	}
 
-	if link.UserId != userId {
-		logger.Debug("User is not a owner")
-		return nil, errs.NewForbiddenError("You don't have access to this link")
+	if appErr := s.access.AuthorizeLink(link, ActionEditLink, ctx); appErr != nil {
+		return nil, appErr
 	}
 
-	// Read the contents of the file into a buffer
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, *file); err != nil {
-		logger.Debugf("Unable to copy the file to the buffer: %s", err.Error())
-		return nil, errs.NewForbiddenError("Unable to attach the file")
+	if !replace {
+		if appErr := checkAttachmentsLimit(link); appErr != nil {
+			return nil, appErr
+		}
 	}
 
-	// This uploads the contents of the buffer to S3
-	_, err := s.s3.PutObject(context.TODO(), &s3.PutObjectInput{
-		Bucket: aws.String(LINK_ATTACHMENTS_BUCKET),
-		Key:    aws.String(headers.Filename),
-		Body:   bytes.NewReader(buf.Bytes()),
-	})
+	sniffed, content, err := sniffContentType(upload.Body)
 	if err != nil {
-		logger.Debugf("Error when uploading the file to AWS S3: %s", err.Error())
-		return nil, errs.NewForbiddenError("Unable to attach the file")
+		logger.Debug("Error while reading the file", zap.Error(err))
+		return nil, errs.NewBadRequestError("Unable to attach the file")
 	}
 
-	awsS3Url := fmt.Sprintf("https://%s.amazonaws.com/%s/%s", "eu-north-1", LINK_ATTACHMENTS_BUCKET, headers.Filename)
-	logger.Debugf("Successfully uploaded the file to AWS S3. Output URL: %s", awsS3Url)
+	// Text formats mostly can't be told apart, so the declared type is kept for plain text
+	contentType := sniffed.String()
+	if mediaType(contentType) == "text/plain" && strings.HasPrefix(mediaType(upload.ContentType), "text/") {
+		contentType = upload.ContentType
+	}
 
-	if err := s.linksTable.Update("ID", id).Range("UserId", userId).Set("Url", awsS3Url).Set("DateUpdated", time.Now().UTC().Format(time.RFC3339)).Run(context.TODO()); err != nil {
-		logger.Debug("Error while updating the link", zap.Error(err))
-		return nil, errs.NewUnexpectedError("Error while updating link")
+	if appErr := s.attachments.checkContentType(mediaType(contentType)); appErr != nil {
+		logger.Debugf("Content type %s is not allowed", contentType)
+		return nil, appErr
 	}
 
-	logger.Debug("Link updated", zap.Any("link", link))
+	maxSize := s.maxAttachmentSize(ctx)
+	uploadId := uuid.NewString()
+	key := attachmentKey(link.ID, uploadId, upload.FileName)
+	hash := sha256.New()
+	body := &limitedReader{reader: io.TeeReader(content, hash), limit: maxSize}
+
+	_, err = s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.s3Options.Bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+	})
+	if body.size > maxSize {
+		logger.Debugf("File is larger than %d bytes", maxSize)
+		return nil, errs.NewPayloadTooLargeError(fmt.Sprintf("File is larger than %d bytes", maxSize))
+	}
 
-	link, appErr = s.getLinkByID(id, userId, ctx)
-	if appErr != nil {
-		return nil, appErr
+	if err != nil {
+		logger.Debug("Error when uploading the file to AWS S3", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Unable to attach the file")
 	}
 
-	return link, nil
+	attachment := domain.Attachment{
+		ID:           uploadId,
+		Key:          key,
+		FileName:     upload.FileName,
+		Size:         body.size,
+		ContentType:  contentType,
+		Checksum:     hex.EncodeToString(hash.Sum(nil)),
+		DateUploaded: time.Now(),
+	}
+
+	logger.Debugf("Successfully uploaded the file to AWS S3. Key: %s", key)
+	return s.attachToLink(link, &attachment, replace, ctx)
 }
 
 func (s *LinkService) DeleteLinkByID(id string, ctx context.Context) (*domain.Link, *errs.AppError) {
-	userId := ctx.Value("UserID").(string)
 	logger := ctx.Value("Logger").(*zap.SugaredLogger)
 
-	link, appErr := s.getLinkByID(id, userId, ctx)
+	link, appErr := s.getLinkByID(id, ctx)
 	if appErr != nil {
 		return nil, appErr
 	}
 
-	if link.UserId != userId {
-		logger.Debug("User is not a owner")
-		return nil, errs.NewForbiddenError("You don't have access to this link")
+	if appErr := s.access.AuthorizeLink(link, ActionDeleteLink, ctx); appErr != nil {
+		return nil, appErr
 	}
 
-	if err := s.linksTable.Delete("ID", id).Range("UserId", userId).Run(context.TODO()); err != nil {
+	if err := s.repo.Delete(id, ownerOf(link), ctx); err != nil {
 		logger.Debug("Error while deleting link", zap.Error(err))
-		return nil, errs.NewUnexpectedError("Error while deleting link")
+		return nil, linkRepositoryError(err, "Error while deleting link")
+	}
+
+	for i := range link.Attachments {
+		s.removeAttachment(&link.Attachments[i], ctx)
 	}
 
 	logger.Debug("Link deleted", zap.Any("link", link))
 	return link, nil
 }
 
-func (s *LinkService) getLinkByID(id string, userId string, ctx context.Context) (*domain.Link, *errs.AppError) {
+// expireLink moves the link to the expired status. The link is returned with
+// the error, so the caller can use its fallback URL.
+func (s *LinkService) expireLink(link *domain.Link, ctx context.Context) (*domain.Link, *errs.AppError) {
 	logger := ctx.Value("Logger").(*zap.SugaredLogger)
-	var link domain.Link
 
-	logger.Debugf("Fetching link by ID: %s and UserId: %s", id, userId)
+	logger.Debug("Link is expired. Updating the status")
 
-	if err := s.linksTable.Get("ID", id).Range("UserId", dynamo.Equal, userId).One(context.TODO(), &link); err != nil {
-		if err == dynamo.ErrNotFound {
-			logger.Debug("Link not found")
-			return nil, errs.NewNotFoundError("Link not found")
-		}
+	expired := domain.Expired
+	if _, err := s.repo.Update(link.ID, &LinkUpdate{Status: &expired, DateUpdated: time.Now()}, ctx); err != nil {
+		logger.Debug("Error while expiring the link", zap.Error(err))
+	}
 
+	link.Status = domain.Expired
+	return link, errs.NewGoneError("Link is expired")
+}
+
+// checkLinkPassword lets the redirect through only with the right password.
+// Wrong passwords are throttled per link and client IP.
+func (s *LinkService) checkLinkPassword(link *domain.Link, request *RedirectRequest, ctx context.Context) *errs.AppError {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if request.Password == nil {
+		logger.Debug("Link is protected with a password")
+		return errs.NewUnauthorizedError("Password required")
+	}
+
+	attemptsKey := link.ID + "|" + request.ClientIP
+	if wait := s.passwordAttempts.Blocked(attemptsKey, time.Now()); wait > 0 {
+		logger.Debugf("Too many wrong passwords. Retry in %s", wait)
+		request.RetryAfter = wait
+		return errs.NewTooManyRequestsError("Too many wrong passwords. Try again later")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(*request.Password)); err != nil {
+		logger.Debug("Wrong password", zap.Error(err))
+		s.passwordAttempts.Fail(attemptsKey, time.Now())
+		return errs.NewUnauthorizedError("Wrong password")
+	}
+
+	s.passwordAttempts.Reset(attemptsKey)
+	return nil
+}
+
+func (s *LinkService) getLinkByID(id string, ctx context.Context) (*domain.Link, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	logger.Debugf("Fetching link by ID: %s", id)
+
+	link, err := s.repo.Get(id, ctx)
+	if err != nil {
 		logger.Debug("Error while fetching link", zap.Error(err))
-		return nil, errs.NewUnexpectedError("Error while fetching link")
+		return nil, linkRepositoryError(err, "Error while fetching link")
 	}
 
-	link.ShortUrl = createShortUrlFromID(link.ID)
+	s.fillLinkFields(link)
 
 	logger.Debug("Link fetched", zap.Any("link", link))
-	return &link, nil
+	return link, nil
 }
 
-func NewLinkService() LinkService {
-	dynamoDB := NewDynamoDBService()
-	s3 := NewS3Service()
-	table := GetOrCreateTable(dynamoDB, "Links", domain.Link{})
+// fillUniqueVisitors sets the all-time unique visitors estimate on the links.
+// The links are returned without it if the stats can't be fetched.
+func (s *LinkService) fillUniqueVisitors(ctx context.Context, links ...*domain.Link) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	linkIds := make([]string, 0, len(links))
+	for _, link := range links {
+		linkIds = append(linkIds, link.ID)
+	}
+
+	visitors, err := s.stats.GetTotalVisitors(linkIds, ctx)
+	if err != nil {
+		logger.Debug("Error while fetching unique visitors", zap.Error(err))
+		return
+	}
+
+	for i := range links {
+		if sketch, ok := visitors[links[i].ID]; ok {
+			links[i].UniqueVisitors = sketch.Estimate()
+		}
+	}
+}
+
+func (s *LinkService) shortUrl(id string) string {
+	if s.domainName == "" {
+		return id
+	}
+
+	return s.domainName + "/" + id
+}
 
-	return LinkService{dynamoDB: dynamoDB, s3: s3, linksTable: table}
+// fillLinkFields sets the fields of a link that aren't stored.
+func (s *LinkService) fillLinkFields(link *domain.Link) {
+	link.ShortUrl = s.shortUrl(link.ID)
+	link.Protected = link.PasswordHash != ""
+
+	// Links made before modes existed have none stored
+	if link.Mode == "" {
+		link.Mode = domain.RedirectMode
+	}
+}
+
+// Short URLs are made of domainName and the link ID, a bare ID is returned without it.
+func NewLinkService(repo LinkRepository, stats LinkStatsRepository, counters RedirectCounter, access *Authorizer, users UserRepository, s3Client *s3.Client, s3Options S3Options, attachments AttachmentOptions, domainName string) LinkService {
+	return LinkService{
+		domainName:       domainName,
+		repo:             repo,
+		stats:            stats,
+		counters:         counters,
+		access:           access,
+		users:            users,
+		passwordAttempts: NewPasswordAttempts(5, time.Minute*15),
+		s3:               s3Client,
+		s3Options:        s3Options,
+		uploader:         manager.NewUploader(s3Client),
+		presigner:        s3.NewPresignClient(s3Client),
+		attachments:      attachments,
+		cleaner:          NewAttachmentCleaner(s3Client, s3Options.Bucket, attachments.RetentionPrefix),
+	}
 }
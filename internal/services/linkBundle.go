@@ -0,0 +1,146 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// The download page may stay open for a while before a file is clicked
+const bundleUrlExpiry = time.Hour
+
+// GetLinkBundle lists the files of a bundle link with their download URLs.
+// The link must have passed GetLinkByIDForRedirect, the ZIP URL is signed so it
+// can be downloaded without unlocking the link again.
+func (s *LinkService) GetLinkBundle(link *domain.Link, ctx context.Context) (*domain.LinkBundle, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	bundle := domain.LinkBundle{Name: link.Name, Files: make([]domain.BundleFile, 0, len(link.Attachments))}
+	if bundle.Name == "" {
+		bundle.Name = link.ID
+	}
+
+	for i := range link.Attachments {
+		attachment := &link.Attachments[i]
+
+		url, err := s.attachmentUrl(attachment, bundleUrlExpiry, ctx)
+		if err != nil {
+			logger.Debug("Error while presigning the attachment URL", zap.Error(err))
+			return nil, errs.NewUnexpectedError("Error while fetching link")
+		}
+
+		bundle.Files = append(bundle.Files, domain.BundleFile{Name: attachment.FileName, Size: attachment.Size, Url: url})
+	}
+
+	if len(link.Attachments) > 0 {
+		expires := strconv.FormatInt(time.Now().Add(bundleUrlExpiry).Unix(), 10)
+		bundle.ZipUrl = "/" + link.ID + "/files.zip?expires=" + expires + "&signature=" + s.bundleSignature(link.ID, expires)
+	}
+
+	return &bundle, nil
+}
+
+// GetLinkBundleForZip checks the signed ZIP URL of GetLinkBundle. The link must still be an active bundle.
+func (s *LinkService) GetLinkBundleForZip(id string, expires string, signature string, ctx context.Context) (*domain.Link, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(s.bundleSignature(id, expires))) {
+		logger.Debug("Invalid bundle signature")
+		return nil, errs.NewForbiddenError("Invalid signature")
+	}
+
+	if time.Now().Unix() > expiresAt {
+		logger.Debug("Bundle URL is expired")
+		return nil, errs.NewForbiddenError("Download link is expired")
+	}
+
+	link, appErr := s.getLinkByID(id, ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if link.Status != domain.Active || isLinkExpired(link, time.Now()) || link.Mode != domain.BundleMode {
+		logger.Debug("Link is not an active bundle")
+		return nil, errs.NewNotFoundError("Link not found")
+	}
+
+	if len(link.Attachments) == 0 {
+		return nil, errs.NewNotFoundError("Link has no attachments")
+	}
+
+	return link, nil
+}
+
+// WriteLinkBundleZip streams the attachments of the link into a ZIP one by one,
+// so neither the objects nor the archive are held in memory. The files are stored
+// without compression, most uploads are compressed already.
+func (s *LinkService) WriteLinkBundleZip(link *domain.Link, w io.Writer, ctx context.Context) error {
+	archive := zip.NewWriter(w)
+	names := make(map[string]bool)
+
+	for _, attachment := range link.Attachments {
+		object, err := s.s3.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.s3Options.Bucket),
+			Key:    aws.String(attachment.Key),
+		})
+		if err != nil {
+			return err
+		}
+
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     zipEntryName(attachment.FileName, names),
+			Method:   zip.Store,
+			Modified: attachment.DateUploaded,
+		})
+		if err == nil {
+			_, err = io.Copy(file, object.Body)
+		}
+
+		object.Body.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func (s *LinkService) bundleSignature(id string, expires string) string {
+	mac := hmac.New(sha256.New, []byte(s.attachments.SigningKey))
+	mac.Write([]byte(id + "|" + expires))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// zipEntryName keeps the entries flat and unique, "a.txt" comes out as "a (2).txt" the second time
+func zipEntryName(fileName string, names map[string]bool) string {
+	name := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = "file"
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 2; names[name]; i++ {
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+
+	names[name] = true
+	return name
+}
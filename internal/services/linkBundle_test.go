@@ -0,0 +1,31 @@
+package services
+
+import "testing"
+
+func TestZipEntryName(t *testing.T) {
+	tests := []struct {
+		name      string
+		fileNames []string
+		want      []string
+	}{
+		{name: "plain", fileNames: []string{"a.txt"}, want: []string{"a.txt"}},
+		{name: "duplicates", fileNames: []string{"a.txt", "a.txt", "a.txt"}, want: []string{"a.txt", "a (2).txt", "a (3).txt"}},
+		{name: "duplicate without extension", fileNames: []string{"README", "README"}, want: []string{"README", "README (2)"}},
+		{name: "same base, other extension", fileNames: []string{"a.txt", "a.pdf"}, want: []string{"a.txt", "a.pdf"}},
+		{name: "taken numbered name", fileNames: []string{"a (2).txt", "a.txt", "a.txt"}, want: []string{"a (2).txt", "a.txt", "a (3).txt"}},
+		{name: "slash path", fileNames: []string{"../../etc/passwd"}, want: []string{"passwd"}},
+		{name: "backslash path", fileNames: []string{`C:\Users\me\photo.jpg`}, want: []string{"photo.jpg"}},
+		{name: "no base name", fileNames: []string{"", "..", "/"}, want: []string{"file", "file (2)", "file (3)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := make(map[string]bool)
+			for i, fileName := range tt.fileNames {
+				if got := zipEntryName(fileName, names); got != tt.want[i] {
+					t.Errorf("zipEntryName(%q) = %q, want %q", fileName, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+var (
+	ErrLinkNotFound       = errors.New("link not found")
+	ErrLinkAlreadyExists  = errors.New("link already exists")
+	ErrCounterLimit       = errors.New("link counter reached the limit")
+	ErrAttachmentNotFound = errors.New("attachment not found")
+)
+
+type LinkCounter string
+
+const (
+	RedirectsCounter    LinkCounter = "Redirects"
+	BotRedirectsCounter LinkCounter = "BotRedirects"
+)
+
+// LinkUpdate describes a partial update of a link. Nil fields are left untouched,
+// zero values of ExpiresAt, MaxRedirects, ExpiredUrl and PasswordHash remove the attribute.
+// Attachments replaces all attachments, AddAttachment appends one and RemoveAttachment
+// removes the one with the given ID. Only one of them can be used in an update.
+// Owner is set when the caller authorized the update against a link it fetched before.
+type LinkUpdate struct {
+	Owner            *LinkOwner
+	Name             *string
+	Url              *string
+	Status           *domain.LinkStatus
+	ExpiresAt        *time.Time
+	MaxRedirects     *int
+	ExpiredUrl       *string
+	PasswordHash     *string
+	Mode             *domain.LinkMode
+	Attachments      *[]domain.Attachment
+	AddAttachment    *domain.Attachment
+	RemoveAttachment *string
+	DateUpdated      time.Time
+}
+
+// LinkOwner is the user and the workspace of a link. Writes made on behalf of a
+// user are only applied while the link still has the owner the access was checked
+// against, a link deleted and created again under the same ID in the meantime is
+// left untouched.
+type LinkOwner struct {
+	UserId      string
+	WorkspaceId string
+}
+
+func ownerOf(link *domain.Link) *LinkOwner {
+	return &LinkOwner{UserId: link.UserId, WorkspaceId: link.WorkspaceId}
+}
+
+func (o *LinkOwner) owns(link *domain.Link) bool {
+	return o == nil || (link.UserId == o.UserId && link.WorkspaceId == o.WorkspaceId)
+}
+
+// LinkCursor points at the last link of a page. It holds every key attribute
+// needed to continue a query on any of the listing indexes.
+type LinkCursor struct {
+	ID          string `json:"i"`
+	UserId      string `json:"u"`
+	WorkspaceId string `json:"w,omitempty"`
+	DateCreated int64  `json:"c,omitempty"`
+	DateUpdated int64  `json:"m,omitempty"`
+	Redirects   int    `json:"r,omitempty"`
+}
+
+type ListLinksOptions struct {
+	Limit int
+	// Status is empty when links with any status should be returned.
+	Status domain.LinkStatus
+	Sort   domain.LinkSort
+	Cursor *LinkCursor
+}
+
+type LinkPage struct {
+	Links      []domain.Link
+	NextCursor *LinkCursor
+}
+
+func newLinkCursor(link *domain.Link) *LinkCursor {
+	return &LinkCursor{
+		ID:          link.ID,
+		UserId:      link.UserId,
+		WorkspaceId: link.WorkspaceId,
+		DateCreated: link.DateCreated.Unix(),
+		DateUpdated: link.DateUpdated.Unix(),
+		Redirects:   link.Redirects,
+	}
+}
+
+// LinkRepository is the storage used by LinkService.
+type LinkRepository interface {
+	// Get returns ErrLinkNotFound if there is no link with the given ID.
+	Get(id string, ctx context.Context) (*domain.Link, error)
+	// Put stores a new link and returns ErrLinkAlreadyExists if the ID is taken.
+	Put(link *domain.Link, ctx context.Context) error
+	// Update applies the update only if the link exists and has update.Owner, otherwise it returns ErrLinkNotFound.
+	// Access to the link is checked by the caller.
+	Update(id string, update *LinkUpdate, ctx context.Context) (*domain.Link, error)
+	// Delete returns ErrLinkNotFound if there is no link with the given ID and owner.
+	Delete(id string, owner *LinkOwner, ctx context.Context) error
+	// ListByUser returns personal links of the user ordered by options.Sort, newest or biggest first.
+	// Links the user created in workspaces aren't included.
+	ListByUser(userId string, options *ListLinksOptions, ctx context.Context) (*LinkPage, error)
+	// ListByWorkspace returns links of the workspace in the same order as ListByUser.
+	ListByWorkspace(workspaceId string, options *ListLinksOptions, ctx context.Context) (*LinkPage, error)
+	IncrementCounter(id string, counter LinkCounter, delta int, ctx context.Context) error
+	// IncrementCounterBelow adds one to the counter only while it is below limit,
+	// otherwise it returns ErrCounterLimit.
+	IncrementCounterBelow(id string, counter LinkCounter, limit int, ctx context.Context) error
+}
+
+func attachmentIndex(attachments []domain.Attachment, id string) int {
+	return slices.IndexFunc(attachments, func(attachment domain.Attachment) bool {
+		return attachment.ID == id
+	})
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/guregu/dynamo/v2"
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+// Every listing order has its own index on UserId and on WorkspaceId, named after its sort key
+var linkSortKeys = map[domain.LinkSort]string{
+	domain.SortByCreated:   "DateCreated",
+	domain.SortByUpdated:   "DateUpdated",
+	domain.SortByRedirects: "Redirects",
+}
+
+var linkIndexes = []dynamo.Index{
+	linksByUserIndex(domain.SortByCreated),
+	linksByUserIndex(domain.SortByUpdated),
+	linksByUserIndex(domain.SortByRedirects),
+	linksByWorkspaceIndex(domain.SortByCreated),
+	linksByWorkspaceIndex(domain.SortByUpdated),
+	linksByWorkspaceIndex(domain.SortByRedirects),
+}
+
+func linksByUserIndex(sortBy domain.LinkSort) dynamo.Index {
+	return linksIndex("UserId", sortBy)
+}
+
+// Links without a workspace aren't in the workspace indexes at all
+func linksByWorkspaceIndex(sortBy domain.LinkSort) dynamo.Index {
+	return linksIndex("WorkspaceId", sortBy)
+}
+
+func linksIndex(hashKey string, sortBy domain.LinkSort) dynamo.Index {
+	rangeKey := linkSortKeys[sortBy]
+
+	return dynamo.Index{
+		Name:           hashKey + "-" + rangeKey + "-index",
+		HashKey:        hashKey,
+		HashKeyType:    dynamo.StringType,
+		RangeKey:       rangeKey,
+		RangeKeyType:   dynamo.NumberType,
+		ProjectionType: dynamo.AllProjection,
+	}
+}
+
+type DynamoDBLinkRepository struct {
+	linksTable dynamo.Table
+}
+
+func (r *DynamoDBLinkRepository) Get(id string, ctx context.Context) (*domain.Link, error) {
+	var link domain.Link
+
+	if err := r.linksTable.Get("ID", id).One(ctx, &link); err != nil {
+		if err == dynamo.ErrNotFound {
+			return nil, ErrLinkNotFound
+		}
+
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+func (r *DynamoDBLinkRepository) Put(link *domain.Link, ctx context.Context) error {
+	err := r.linksTable.Put(link).If("attribute_not_exists('ID')").Run(ctx)
+	if dynamo.IsCondCheckFailed(err) {
+		return ErrLinkAlreadyExists
+	}
+
+	return err
+}
+
+func (r *DynamoDBLinkRepository) Update(id string, update *LinkUpdate, ctx context.Context) (*domain.Link, error) {
+	var link domain.Link
+
+	query := r.linksTable.Update("ID", id).If("attribute_exists('ID')")
+	if update.Owner != nil {
+		expr, args := ownerCondition(update.Owner)
+		query = query.If(expr, args...)
+	}
+
+	if update.Name != nil {
+		query = query.Set("Name", *update.Name)
+	}
+
+	if update.Url != nil {
+		query = query.Set("Url", *update.Url)
+	}
+
+	if update.Status != nil {
+		query = query.Set("Status", *update.Status)
+	}
+
+	if update.ExpiresAt != nil {
+		if update.ExpiresAt.IsZero() {
+			query = query.Remove("ExpiresAt")
+		} else {
+			query = query.Set("ExpiresAt", update.ExpiresAt.Unix())
+		}
+	}
+
+	if update.MaxRedirects != nil {
+		if *update.MaxRedirects == 0 {
+			query = query.Remove("MaxRedirects")
+		} else {
+			query = query.Set("MaxRedirects", *update.MaxRedirects)
+		}
+	}
+
+	if update.ExpiredUrl != nil {
+		if *update.ExpiredUrl == "" {
+			query = query.Remove("ExpiredUrl")
+		} else {
+			query = query.Set("ExpiredUrl", *update.ExpiredUrl)
+		}
+	}
+
+	if update.PasswordHash != nil {
+		if *update.PasswordHash == "" {
+			query = query.Remove("PasswordHash")
+		} else {
+			query = query.Set("PasswordHash", *update.PasswordHash)
+		}
+	}
+
+	if update.Mode != nil {
+		query = query.Set("Mode", *update.Mode)
+	}
+
+	if update.Attachments != nil {
+		if len(*update.Attachments) == 0 {
+			query = query.Remove("Attachments")
+		} else {
+			query = query.Set("Attachments", *update.Attachments)
+		}
+	}
+
+	if update.AddAttachment != nil {
+		query = query.SetExpr("$ = list_append(if_not_exists($, ?), ?)", "Attachments", "Attachments", []domain.Attachment{}, []domain.Attachment{*update.AddAttachment})
+	}
+
+	if update.RemoveAttachment != nil {
+		// DynamoDB removes list elements by index only, the condition makes sure
+		// the list didn't change since the index was looked up
+		current, err := r.Get(id, ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		index := attachmentIndex(current.Attachments, *update.RemoveAttachment)
+		if index < 0 {
+			return nil, ErrAttachmentNotFound
+		}
+
+		query = query.RemoveExpr("Attachments[$]", index).If("Attachments[$].ID = ?", index, *update.RemoveAttachment)
+	}
+
+	if !update.DateUpdated.IsZero() {
+		query = query.Set("DateUpdated", update.DateUpdated.Unix())
+	}
+
+	if err := query.Value(ctx, &link); err != nil {
+		if dynamo.IsCondCheckFailed(err) && update.RemoveAttachment != nil {
+			return nil, ErrAttachmentNotFound
+		}
+
+		if dynamo.IsCondCheckFailed(err) {
+			return nil, ErrLinkNotFound
+		}
+
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+func (r *DynamoDBLinkRepository) Delete(id string, owner *LinkOwner, ctx context.Context) error {
+	query := r.linksTable.Delete("ID", id).If("attribute_exists('ID')")
+	if owner != nil {
+		expr, args := ownerCondition(owner)
+		query = query.If(expr, args...)
+	}
+
+	err := query.Run(ctx)
+	if dynamo.IsCondCheckFailed(err) {
+		return ErrLinkNotFound
+	}
+
+	return err
+}
+
+// Personal links have no WorkspaceId attribute at all
+func ownerCondition(owner *LinkOwner) (string, []interface{}) {
+	if owner.WorkspaceId == "" {
+		return "'UserId' = ? AND attribute_not_exists('WorkspaceId')", []interface{}{owner.UserId}
+	}
+
+	return "'UserId' = ? AND 'WorkspaceId' = ?", []interface{}{owner.UserId, owner.WorkspaceId}
+}
+
+func (r *DynamoDBLinkRepository) ListByUser(userId string, options *ListLinksOptions, ctx context.Context) (*LinkPage, error) {
+	sortBy := linkSortOrDefault(options.Sort)
+
+	query := r.linksTable.Get("UserId", userId).
+		Index(linksByUserIndex(sortBy).Name).
+		Filter("attribute_not_exists('WorkspaceId')")
+
+	return r.list(query, "UserId", options, ctx)
+}
+
+func (r *DynamoDBLinkRepository) ListByWorkspace(workspaceId string, options *ListLinksOptions, ctx context.Context) (*LinkPage, error) {
+	sortBy := linkSortOrDefault(options.Sort)
+
+	query := r.linksTable.Get("WorkspaceId", workspaceId).Index(linksByWorkspaceIndex(sortBy).Name)
+
+	return r.list(query, "WorkspaceId", options, ctx)
+}
+
+// list runs a query on one of the listing indexes, hashKey is the hash key of that index.
+func (r *DynamoDBLinkRepository) list(query *dynamo.Query, hashKey string, options *ListLinksOptions, ctx context.Context) (*LinkPage, error) {
+	var links []domain.Link
+
+	sortBy := linkSortOrDefault(options.Sort)
+	query = query.Order(dynamo.Descending)
+
+	if options.Status != "" {
+		query = query.Filter("'Status' = ?", options.Status)
+	}
+
+	if options.Limit > 0 {
+		query = query.Limit(options.Limit)
+	}
+
+	if options.Cursor != nil {
+		query = query.StartFrom(linkCursorToPagingKey(options.Cursor, hashKey, sortBy))
+	}
+
+	lastKey, err := query.AllWithLastEvaluatedKey(ctx, &links)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &LinkPage{Links: links}
+	if lastKey != nil && len(links) > 0 {
+		page.NextCursor = newLinkCursor(&links[len(links)-1])
+	}
+
+	return page, nil
+}
+
+func (r *DynamoDBLinkRepository) IncrementCounter(id string, counter LinkCounter, delta int, ctx context.Context) error {
+	err := r.linksTable.Update("ID", id).If("attribute_exists('ID')").Add(string(counter), delta).Run(ctx)
+	if dynamo.IsCondCheckFailed(err) {
+		return ErrLinkNotFound
+	}
+
+	return err
+}
+
+func (r *DynamoDBLinkRepository) IncrementCounterBelow(id string, counter LinkCounter, limit int, ctx context.Context) error {
+	var link domain.Link
+
+	err := r.linksTable.Update("ID", id).
+		If("attribute_exists('ID')").
+		If("attribute_not_exists($) OR $ < ?", string(counter), string(counter), limit).
+		Add(string(counter), 1).
+		IncludeItemInCondCheckFail(true).
+		Run(ctx)
+	if dynamo.IsCondCheckFailed(err) {
+		// The failed item is returned to tell a missing link from a reached limit
+		if _, unmarshalErr := dynamo.UnmarshalItemFromCondCheckFailed(err, &link); unmarshalErr == nil && link.ID != "" {
+			return ErrCounterLimit
+		}
+
+		return ErrLinkNotFound
+	}
+
+	return err
+}
+
+func linkSortOrDefault(sortBy domain.LinkSort) domain.LinkSort {
+	if _, ok := linkSortKeys[sortBy]; !ok {
+		return domain.SortByCreated
+	}
+
+	return sortBy
+}
+
+func linkCursorToPagingKey(cursor *LinkCursor, hashKey string, sortBy domain.LinkSort) dynamo.PagingKey {
+	var sortValue int64
+
+	switch sortBy {
+	case domain.SortByUpdated:
+		sortValue = cursor.DateUpdated
+	case domain.SortByRedirects:
+		sortValue = int64(cursor.Redirects)
+	default:
+		sortValue = cursor.DateCreated
+	}
+
+	hashValue := cursor.UserId
+	if hashKey == "WorkspaceId" {
+		hashValue = cursor.WorkspaceId
+	}
+
+	return dynamo.PagingKey{
+		"ID":                 &types.AttributeValueMemberS{Value: cursor.ID},
+		hashKey:              &types.AttributeValueMemberS{Value: hashValue},
+		linkSortKeys[sortBy]: &types.AttributeValueMemberN{Value: strconv.FormatInt(sortValue, 10)},
+	}
+}
+
+func NewDynamoDBLinkRepository(db *dynamo.DB) *DynamoDBLinkRepository {
+	table := GetOrCreateTable(db, "Links", domain.Link{}, linkIndexes...)
+
+	return &DynamoDBLinkRepository{linksTable: table}
+}
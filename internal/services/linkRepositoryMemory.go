@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+// MemoryLinkRepository keeps links in process memory.
+type MemoryLinkRepository struct {
+	mu    sync.RWMutex
+	links map[string]domain.Link
+}
+
+func (r *MemoryLinkRepository) Get(id string, ctx context.Context) (*domain.Link, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	link, ok := r.links[id]
+	if !ok {
+		return nil, ErrLinkNotFound
+	}
+
+	return &link, nil
+}
+
+func (r *MemoryLinkRepository) Put(link *domain.Link, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.links[link.ID]; ok {
+		return ErrLinkAlreadyExists
+	}
+
+	r.links[link.ID] = *link
+	return nil
+}
+
+func (r *MemoryLinkRepository) Update(id string, update *LinkUpdate, ctx context.Context) (*domain.Link, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.links[id]
+	if !ok || !update.Owner.owns(&link) {
+		return nil, ErrLinkNotFound
+	}
+
+	if update.Name != nil {
+		link.Name = *update.Name
+	}
+
+	if update.Url != nil {
+		link.Url = *update.Url
+	}
+
+	if update.Status != nil {
+		link.Status = *update.Status
+	}
+
+	if update.ExpiresAt != nil {
+		if update.ExpiresAt.IsZero() {
+			link.ExpiresAt = nil
+		} else {
+			expiresAt := *update.ExpiresAt
+			link.ExpiresAt = &expiresAt
+		}
+	}
+
+	if update.MaxRedirects != nil {
+		link.MaxRedirects = *update.MaxRedirects
+	}
+
+	if update.ExpiredUrl != nil {
+		link.ExpiredUrl = *update.ExpiredUrl
+	}
+
+	if update.PasswordHash != nil {
+		link.PasswordHash = *update.PasswordHash
+	}
+
+	if update.Mode != nil {
+		link.Mode = *update.Mode
+	}
+
+	if update.Attachments != nil {
+		link.Attachments = append([]domain.Attachment(nil), *update.Attachments...)
+	}
+
+	if update.AddAttachment != nil {
+		link.Attachments = append(link.Attachments, *update.AddAttachment)
+	}
+
+	if update.RemoveAttachment != nil {
+		index := attachmentIndex(link.Attachments, *update.RemoveAttachment)
+		if index < 0 {
+			return nil, ErrAttachmentNotFound
+		}
+
+		link.Attachments = slices.Delete(slices.Clone(link.Attachments), index, index+1)
+	}
+
+	if !update.DateUpdated.IsZero() {
+		link.DateUpdated = update.DateUpdated
+	}
+
+	r.links[id] = link
+	return &link, nil
+}
+
+func (r *MemoryLinkRepository) Delete(id string, owner *LinkOwner, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if link, ok := r.links[id]; !ok || !owner.owns(&link) {
+		return ErrLinkNotFound
+	}
+
+	delete(r.links, id)
+	return nil
+}
+
+func (r *MemoryLinkRepository) ListByUser(userId string, options *ListLinksOptions, ctx context.Context) (*LinkPage, error) {
+	return r.list(func(link *domain.Link) bool {
+		return link.UserId == userId && link.WorkspaceId == ""
+	}, options)
+}
+
+func (r *MemoryLinkRepository) ListByWorkspace(workspaceId string, options *ListLinksOptions, ctx context.Context) (*LinkPage, error) {
+	return r.list(func(link *domain.Link) bool {
+		return link.WorkspaceId == workspaceId
+	}, options)
+}
+
+func (r *MemoryLinkRepository) list(match func(link *domain.Link) bool, options *ListLinksOptions) (*LinkPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var links []domain.Link
+	for _, link := range r.links {
+		if !match(&link) {
+			continue
+		}
+
+		if options.Status != "" && link.Status != options.Status {
+			continue
+		}
+
+		links = append(links, link)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return memoryLinkCursorLess(newLinkCursor(&links[j]), newLinkCursor(&links[i]), options.Sort)
+	})
+
+	// Skip everything up to and including the cursor
+	if options.Cursor != nil {
+		start := sort.Search(len(links), func(i int) bool {
+			return memoryLinkCursorLess(newLinkCursor(&links[i]), options.Cursor, options.Sort)
+		})
+		links = links[start:]
+	}
+
+	page := &LinkPage{Links: links}
+	if options.Limit > 0 && len(links) > options.Limit {
+		page.Links = links[:options.Limit]
+		page.NextCursor = newLinkCursor(&page.Links[options.Limit-1])
+	}
+
+	return page, nil
+}
+
+// memoryLinkCursorLess orders links the same way the DynamoDB listing indexes do,
+// breaking ties by ID.
+func memoryLinkCursorLess(a *LinkCursor, b *LinkCursor, sortBy domain.LinkSort) bool {
+	var av, bv int64
+
+	switch sortBy {
+	case domain.SortByUpdated:
+		av, bv = a.DateUpdated, b.DateUpdated
+	case domain.SortByRedirects:
+		av, bv = int64(a.Redirects), int64(b.Redirects)
+	default:
+		av, bv = a.DateCreated, b.DateCreated
+	}
+
+	if av != bv {
+		return av < bv
+	}
+
+	return a.ID < b.ID
+}
+
+func (r *MemoryLinkRepository) IncrementCounter(id string, counter LinkCounter, delta int, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.links[id]
+	if !ok {
+		return ErrLinkNotFound
+	}
+
+	*memoryLinkCounter(&link, counter) += delta
+
+	r.links[id] = link
+	return nil
+}
+
+func (r *MemoryLinkRepository) IncrementCounterBelow(id string, counter LinkCounter, limit int, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	link, ok := r.links[id]
+	if !ok {
+		return ErrLinkNotFound
+	}
+
+	value := memoryLinkCounter(&link, counter)
+	if *value >= limit {
+		return ErrCounterLimit
+	}
+
+	*value++
+
+	r.links[id] = link
+	return nil
+}
+
+func memoryLinkCounter(link *domain.Link, counter LinkCounter) *int {
+	switch counter {
+	case BotRedirectsCounter:
+		return &link.BotRedirects
+	default:
+		return &link.Redirects
+	}
+}
+
+func NewMemoryLinkRepository() *MemoryLinkRepository {
+	return &MemoryLinkRepository{links: make(map[string]domain.Link)}
+}
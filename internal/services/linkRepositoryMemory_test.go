@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+func TestMemoryLinkRepositoryPut(t *testing.T) {
+	repo := NewMemoryLinkRepository()
+	ctx := context.Background()
+
+	if err := repo.Put(&domain.Link{ID: "a", UserId: "alice", Url: "https://a.example"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Put(&domain.Link{ID: "a", UserId: "bob", Url: "https://b.example"}, ctx); err != ErrLinkAlreadyExists {
+		t.Fatalf("Put() of a taken ID error = %v, want %v", err, ErrLinkAlreadyExists)
+	}
+
+	link, err := repo.Get("a", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if link.UserId != "alice" || link.Url != "https://a.example" {
+		t.Errorf("Put() of a taken ID replaced the link: %+v", link)
+	}
+}
+
+func TestMemoryLinkRepositoryOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		owner   *LinkOwner
+		wantErr error
+	}{
+		{name: "unchecked", owner: nil},
+		{name: "same owner", owner: &LinkOwner{UserId: "alice", WorkspaceId: "w1"}},
+		{name: "other user", owner: &LinkOwner{UserId: "bob", WorkspaceId: "w1"}, wantErr: ErrLinkNotFound},
+		{name: "other workspace", owner: &LinkOwner{UserId: "alice", WorkspaceId: "w2"}, wantErr: ErrLinkNotFound},
+		{name: "personal link", owner: &LinkOwner{UserId: "alice"}, wantErr: ErrLinkNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewMemoryLinkRepository()
+			ctx := context.Background()
+
+			if err := repo.Put(&domain.Link{ID: "a", UserId: "alice", WorkspaceId: "w1", Name: "before"}, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			name := "after"
+			if _, err := repo.Update("a", &LinkUpdate{Owner: tt.owner, Name: &name}, ctx); err != tt.wantErr {
+				t.Fatalf("Update() error = %v, want %v", err, tt.wantErr)
+			}
+
+			link, _ := repo.Get("a", ctx)
+			if updated := link.Name == name; updated != (tt.wantErr == nil) {
+				t.Errorf("Update() left the name %q", link.Name)
+			}
+
+			if err := repo.Delete("a", tt.owner, ctx); err != tt.wantErr {
+				t.Fatalf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+
+			_, err := repo.Get("a", ctx)
+			if deleted := err == ErrLinkNotFound; deleted != (tt.wantErr == nil) {
+				t.Errorf("Delete() left the link: %v", err)
+			}
+		})
+	}
+}
+
+func TestMemoryLinkRepositoryList(t *testing.T) {
+	repo := NewMemoryLinkRepository()
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	links := []domain.Link{
+		{ID: "a", UserId: "alice", Status: domain.Active, Redirects: 5, DateCreated: now.Add(-4 * time.Hour), DateUpdated: now.Add(-1 * time.Hour)},
+		{ID: "b", UserId: "alice", Status: domain.Paused, Redirects: 1, DateCreated: now.Add(-3 * time.Hour), DateUpdated: now.Add(-3 * time.Hour)},
+		{ID: "c", UserId: "alice", Status: domain.Active, Redirects: 5, DateCreated: now.Add(-2 * time.Hour), DateUpdated: now.Add(-2 * time.Hour)},
+		{ID: "d", UserId: "alice", Status: domain.Active, Redirects: 9, DateCreated: now.Add(-2 * time.Hour), DateUpdated: now.Add(-4 * time.Hour)},
+		{ID: "e", UserId: "alice", WorkspaceId: "w1", Status: domain.Active, DateCreated: now, DateUpdated: now},
+		{ID: "f", UserId: "bob", Status: domain.Active, DateCreated: now, DateUpdated: now},
+	}
+
+	for i := range links {
+		if err := repo.Put(&links[i], ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		status domain.LinkStatus
+		sort   domain.LinkSort
+		want   []string
+	}{
+		{name: "created", sort: domain.SortByCreated, want: []string{"d", "c", "b", "a"}},
+		{name: "updated", sort: domain.SortByUpdated, want: []string{"a", "c", "b", "d"}},
+		{name: "redirects", sort: domain.SortByRedirects, want: []string{"d", "c", "a", "b"}},
+		{name: "status", status: domain.Active, sort: domain.SortByCreated, want: []string{"d", "c", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			options := ListLinksOptions{Limit: 2, Status: tt.status, Sort: tt.sort}
+
+			// Follow the cursors page by page
+			for pages := 0; pages < len(links); pages++ {
+				page, err := repo.ListByUser("alice", &options, ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				for _, link := range page.Links {
+					got = append(got, link.ID)
+				}
+
+				if page.NextCursor == nil {
+					break
+				}
+
+				options.Cursor = page.NextCursor
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListByUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	page, err := repo.ListByWorkspace("w1", &ListLinksOptions{Sort: domain.SortByCreated}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Links) != 1 || page.Links[0].ID != "e" || page.NextCursor != nil {
+		t.Errorf("ListByWorkspace() = %+v, want only link e", page)
+	}
+}
+
+func TestMemoryLinkRepositoryIncrementCounterBelow(t *testing.T) {
+	repo := NewMemoryLinkRepository()
+	ctx := context.Background()
+
+	if err := repo.Put(&domain.Link{ID: "a", UserId: "alice"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, want := range []error{nil, nil, ErrCounterLimit, ErrCounterLimit} {
+		if err := repo.IncrementCounterBelow("a", RedirectsCounter, 2, ctx); err != want {
+			t.Fatalf("IncrementCounterBelow() call %d error = %v, want %v", i+1, err, want)
+		}
+	}
+
+	link, _ := repo.Get("a", ctx)
+	if link.Redirects != 2 || link.BotRedirects != 0 {
+		t.Errorf("counters = %d redirects, %d bot redirects, want 2 and 0", link.Redirects, link.BotRedirects)
+	}
+
+	if err := repo.IncrementCounterBelow("missing", RedirectsCounter, 2, ctx); err != ErrLinkNotFound {
+		t.Errorf("IncrementCounterBelow() of a missing link error = %v, want %v", err, ErrLinkNotFound)
+	}
+}
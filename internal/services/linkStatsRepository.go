@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/the-redx/link-shortener/pkg/hll"
+)
+
+// TotalStatsPeriod is the period of the all-time stats record. Daily records use statsDayFormat.
+const TotalStatsPeriod = "total"
+
+const statsDayFormat = time.DateOnly
+
+// LinkStatsRepository stores aggregated stats of links per day and for all time.
+type LinkStatsRepository interface {
+	// MergeVisitors merges the sketch into the stored one, creating the record if needed.
+	MergeVisitors(linkId string, period string, visitors *hll.Sketch, ctx context.Context) error
+	// ListDailyVisitors returns the daily sketches of the link between the two days inclusive, keyed by day.
+	ListDailyVisitors(linkId string, fromDay string, toDay string, ctx context.Context) (map[string]*hll.Sketch, error)
+	// GetTotalVisitors returns the all-time sketches of the links. Links without stats are skipped.
+	GetTotalVisitors(linkIds []string, ctx context.Context) (map[string]*hll.Sketch, error)
+}
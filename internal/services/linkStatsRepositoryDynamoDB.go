@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/guregu/dynamo/v2"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/hll"
+)
+
+// Concurrent merges of the same record are retried this many times
+const statsMergeAttempts = 5
+
+var errStatsMergeConflict = errors.New("too many concurrent updates of link stats")
+
+type DynamoDBLinkStatsRepository struct {
+	statsTable dynamo.Table
+}
+
+func (r *DynamoDBLinkStatsRepository) MergeVisitors(linkId string, period string, visitors *hll.Sketch, ctx context.Context) error {
+	for attempt := 0; attempt < statsMergeAttempts; attempt++ {
+		var record domain.LinkStatsRecord
+		sketch := hll.New()
+
+		err := r.statsTable.Get("LinkId", linkId).Range("Period", dynamo.Equal, period).One(ctx, &record)
+		if err != nil && err != dynamo.ErrNotFound {
+			return err
+		}
+
+		exists := err == nil
+		if exists {
+			if err := sketch.UnmarshalBinary(record.Visitors); err != nil {
+				return err
+			}
+		}
+
+		sketch.Merge(visitors)
+
+		data, err := sketch.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		put := r.statsTable.Put(domain.LinkStatsRecord{
+			LinkId:   linkId,
+			Period:   period,
+			Visitors: data,
+			Version:  record.Version + 1,
+		})
+
+		// Optimistic locking: somebody else may have merged in the meantime
+		if exists {
+			put = put.If("'Version' = ?", record.Version)
+		} else {
+			put = put.If("attribute_not_exists('LinkId')")
+		}
+
+		err = put.Run(ctx)
+		if !dynamo.IsCondCheckFailed(err) {
+			return err
+		}
+	}
+
+	return errStatsMergeConflict
+}
+
+func (r *DynamoDBLinkStatsRepository) ListDailyVisitors(linkId string, fromDay string, toDay string, ctx context.Context) (map[string]*hll.Sketch, error) {
+	var records []domain.LinkStatsRecord
+
+	if err := r.statsTable.Get("LinkId", linkId).Range("Period", dynamo.Between, fromDay, toDay).All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	return decodeVisitorSketches(records, func(record *domain.LinkStatsRecord) string {
+		return record.Period
+	})
+}
+
+func (r *DynamoDBLinkStatsRepository) GetTotalVisitors(linkIds []string, ctx context.Context) (map[string]*hll.Sketch, error) {
+	var records []domain.LinkStatsRecord
+
+	if len(linkIds) == 0 {
+		return map[string]*hll.Sketch{}, nil
+	}
+
+	keys := make([]dynamo.Keyed, 0, len(linkIds))
+	for _, linkId := range linkIds {
+		keys = append(keys, dynamo.Keys{linkId, TotalStatsPeriod})
+	}
+
+	err := r.statsTable.Batch("LinkId", "Period").Get(keys...).All(ctx, &records)
+	if err != nil && err != dynamo.ErrNotFound {
+		return nil, err
+	}
+
+	return decodeVisitorSketches(records, func(record *domain.LinkStatsRecord) string {
+		return record.LinkId
+	})
+}
+
+func decodeVisitorSketches(records []domain.LinkStatsRecord, keyOf func(record *domain.LinkStatsRecord) string) (map[string]*hll.Sketch, error) {
+	sketches := make(map[string]*hll.Sketch, len(records))
+
+	for i := range records {
+		sketch := hll.New()
+		if err := sketch.UnmarshalBinary(records[i].Visitors); err != nil {
+			return nil, err
+		}
+
+		sketches[keyOf(&records[i])] = sketch
+	}
+
+	return sketches, nil
+}
+
+func NewDynamoDBLinkStatsRepository(db *dynamo.DB) *DynamoDBLinkStatsRepository {
+	table := GetOrCreateTable(db, "LinkStats", domain.LinkStatsRecord{})
+
+	return &DynamoDBLinkStatsRepository{statsTable: table}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"github.com/the-redx/link-shortener/pkg/hll"
+)
+
+// MemoryLinkStatsRepository keeps link stats in process memory.
+type MemoryLinkStatsRepository struct {
+	mu       sync.RWMutex
+	visitors map[string]map[string]*hll.Sketch
+}
+
+func (r *MemoryLinkStatsRepository) MergeVisitors(linkId string, period string, visitors *hll.Sketch, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	periods, ok := r.visitors[linkId]
+	if !ok {
+		periods = make(map[string]*hll.Sketch)
+		r.visitors[linkId] = periods
+	}
+
+	sketch, ok := periods[period]
+	if !ok {
+		sketch = hll.New()
+		periods[period] = sketch
+	}
+
+	sketch.Merge(visitors)
+	return nil
+}
+
+func (r *MemoryLinkStatsRepository) ListDailyVisitors(linkId string, fromDay string, toDay string, ctx context.Context) (map[string]*hll.Sketch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sketches := make(map[string]*hll.Sketch)
+	for period, sketch := range r.visitors[linkId] {
+		if period != TotalStatsPeriod && period >= fromDay && period <= toDay {
+			sketches[period] = copySketch(sketch)
+		}
+	}
+
+	return sketches, nil
+}
+
+func (r *MemoryLinkStatsRepository) GetTotalVisitors(linkIds []string, ctx context.Context) (map[string]*hll.Sketch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sketches := make(map[string]*hll.Sketch)
+	for _, linkId := range linkIds {
+		if sketch, ok := r.visitors[linkId][TotalStatsPeriod]; ok {
+			sketches[linkId] = copySketch(sketch)
+		}
+	}
+
+	return sketches, nil
+}
+
+func copySketch(sketch *hll.Sketch) *hll.Sketch {
+	copied := hll.New()
+	copied.Merge(sketch)
+
+	return copied
+}
+
+func NewMemoryLinkStatsRepository() *MemoryLinkStatsRepository {
+	return &MemoryLinkStatsRepository{visitors: make(map[string]map[string]*hll.Sketch)}
+}
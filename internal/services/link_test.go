@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	utils.Logger = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+func newTestLinkService() (*LinkService, *MemoryLinkRepository, *MemoryWorkspaceRepository) {
+	repo := NewMemoryLinkRepository()
+	workspaces := NewMemoryWorkspaceRepository()
+	s3Client := s3.New(s3.Options{Region: "us-east-1"})
+
+	service := NewLinkService(repo, NewMemoryLinkStatsRepository(), NewDirectRedirectCounter(repo), NewAuthorizer(workspaces), NewMemoryUserRepository(), s3Client, S3Options{}, AttachmentOptions{}, "")
+	return &service, repo, workspaces
+}
+
+func linkTestContext(userId string) context.Context {
+	ctx := context.WithValue(context.Background(), "Logger", zap.NewNop().Sugar())
+	if userId == "" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, "UserID", userId)
+}
+
+func TestLinkServiceRedirect(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		link          domain.Link
+		bot           bool
+		wantCode      int
+		wantStatus    domain.LinkStatus
+		wantRedirects int
+		wantBots      int
+	}{
+		{name: "active", link: domain.Link{Status: domain.Active}, wantStatus: domain.Active, wantRedirects: 1},
+		{name: "bot", link: domain.Link{Status: domain.Active}, bot: true, wantStatus: domain.Active, wantBots: 1},
+		{name: "paused", link: domain.Link{Status: domain.Paused}, wantCode: http.StatusNotFound, wantStatus: domain.Paused},
+		{name: "expired", link: domain.Link{Status: domain.Expired}, wantCode: http.StatusGone, wantStatus: domain.Expired},
+		{name: "not expired yet", link: domain.Link{Status: domain.Active, ExpiresAt: &future}, wantStatus: domain.Active, wantRedirects: 1},
+		{name: "expiry date passed", link: domain.Link{Status: domain.Active, ExpiresAt: &past}, wantCode: http.StatusGone, wantStatus: domain.Expired},
+		{name: "below max redirects", link: domain.Link{Status: domain.Active, MaxRedirects: 2, Redirects: 1}, wantStatus: domain.Active, wantRedirects: 2},
+		{name: "max redirects reached", link: domain.Link{Status: domain.Active, MaxRedirects: 2, Redirects: 2}, wantCode: http.StatusGone, wantStatus: domain.Expired, wantRedirects: 2},
+		{name: "bot below max redirects", link: domain.Link{Status: domain.Active, MaxRedirects: 2, Redirects: 1}, bot: true, wantStatus: domain.Active, wantRedirects: 1, wantBots: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, repo, _ := newTestLinkService()
+			ctx := linkTestContext("")
+
+			tt.link.ID = "a"
+			tt.link.UserId = "alice"
+			tt.link.Url = "https://example.com"
+			if err := repo.Put(&tt.link, ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			link, appErr := service.GetLinkByIDForRedirect("a", &RedirectRequest{Bot: tt.bot}, ctx)
+			if code := errorCode(appErr); code != tt.wantCode {
+				t.Fatalf("GetLinkByIDForRedirect() code = %d, want %d", code, tt.wantCode)
+			}
+
+			if appErr == nil && link.Url != "https://example.com" {
+				t.Errorf("GetLinkByIDForRedirect() url = %q", link.Url)
+			}
+
+			stored, _ := repo.Get("a", ctx)
+			if stored.Status != tt.wantStatus || stored.Redirects != tt.wantRedirects || stored.BotRedirects != tt.wantBots {
+				t.Errorf("stored link: status %s, %d redirects, %d bot redirects, want %s, %d and %d",
+					stored.Status, stored.Redirects, stored.BotRedirects, tt.wantStatus, tt.wantRedirects, tt.wantBots)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		service, _, _ := newTestLinkService()
+
+		if _, appErr := service.GetLinkByIDForRedirect("missing", &RedirectRequest{}, linkTestContext("")); errorCode(appErr) != http.StatusNotFound {
+			t.Errorf("GetLinkByIDForRedirect() of a missing link = %v, want 404", appErr)
+		}
+	})
+}
+
+func TestLinkServiceRedirectPassword(t *testing.T) {
+	service, _, _ := newTestLinkService()
+
+	link, appErr := service.CreateLink(&domain.CreateLinkDTO{ID: "secret", Url: "https://example.com", Password: "right-password"}, linkTestContext("alice"))
+	if appErr != nil {
+		t.Fatal(appErr.ErrorMessage)
+	}
+
+	if !link.Protected {
+		t.Fatal("CreateLink() with a password returned an unprotected link")
+	}
+
+	password := func(value string) *string {
+		return &value
+	}
+
+	steps := []struct {
+		name           string
+		clientIP       string
+		password       *string
+		wantCode       int
+		wantRetryAfter bool
+	}{
+		{name: "no password", clientIP: "203.0.113.1", wantCode: http.StatusUnauthorized},
+		{name: "right password", clientIP: "203.0.113.1", password: password("right-password")},
+		{name: "wrong password 1", clientIP: "203.0.113.1", password: password("wrong"), wantCode: http.StatusUnauthorized},
+		{name: "wrong password 2", clientIP: "203.0.113.1", password: password("wrong"), wantCode: http.StatusUnauthorized},
+		{name: "wrong password 3", clientIP: "203.0.113.1", password: password("wrong"), wantCode: http.StatusUnauthorized},
+		{name: "wrong password 4", clientIP: "203.0.113.1", password: password("wrong"), wantCode: http.StatusUnauthorized},
+		{name: "wrong password 5", clientIP: "203.0.113.1", password: password("wrong"), wantCode: http.StatusUnauthorized},
+		{name: "throttled", clientIP: "203.0.113.1", password: password("right-password"), wantCode: http.StatusTooManyRequests, wantRetryAfter: true},
+		{name: "other client", clientIP: "203.0.113.2", password: password("right-password")},
+	}
+
+	for _, step := range steps {
+		request := RedirectRequest{ClientIP: step.clientIP, Password: step.password}
+
+		link, appErr := service.GetLinkByIDForRedirect("secret", &request, linkTestContext(""))
+		if code := errorCode(appErr); code != step.wantCode {
+			t.Fatalf("%s: GetLinkByIDForRedirect() code = %d, want %d", step.name, code, step.wantCode)
+		}
+
+		if appErr != nil && (link == nil || !link.Protected) {
+			t.Errorf("%s: a rejected redirect must return the protected link for the unlock page", step.name)
+		}
+
+		if (request.RetryAfter > 0) != step.wantRetryAfter {
+			t.Errorf("%s: RetryAfter = %s", step.name, request.RetryAfter)
+		}
+	}
+}
+
+func TestLinkServiceAuthorization(t *testing.T) {
+	service, repo, workspaces := newTestLinkService()
+	ctx := linkTestContext("")
+
+	if err := workspaces.Put(&domain.Workspace{ID: "w1"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, member := range []domain.WorkspaceMember{
+		{WorkspaceId: "w1", UserId: "owner", Role: domain.RoleOwner},
+		{WorkspaceId: "w1", UserId: "editor", Role: domain.RoleEditor},
+		{WorkspaceId: "w1", UserId: "viewer", Role: domain.RoleViewer},
+	} {
+		if err := workspaces.PutMember(&member, i, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := []domain.Link{
+		{ID: "personal", UserId: "alice", Url: "https://example.com", Status: domain.Active},
+		{ID: "shared", UserId: "owner", WorkspaceId: "w1", Url: "https://example.com", Status: domain.Active},
+	}
+
+	for i := range links {
+		if err := repo.Put(&links[i], ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		linkId     string
+		userId     string
+		wantView   int
+		wantUpdate int
+	}{
+		{linkId: "personal", userId: "alice"},
+		{linkId: "personal", userId: "bob", wantView: http.StatusForbidden, wantUpdate: http.StatusForbidden},
+		{linkId: "personal", userId: "owner", wantView: http.StatusForbidden, wantUpdate: http.StatusForbidden},
+		{linkId: "shared", userId: "owner"},
+		{linkId: "shared", userId: "editor"},
+		{linkId: "shared", userId: "viewer", wantUpdate: http.StatusForbidden},
+		{linkId: "shared", userId: "alice", wantView: http.StatusForbidden, wantUpdate: http.StatusForbidden},
+		{linkId: "missing", userId: "alice", wantView: http.StatusNotFound, wantUpdate: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.linkId+" by "+tt.userId, func(t *testing.T) {
+			ctx := linkTestContext(tt.userId)
+
+			if _, appErr := service.GetLinkByID(tt.linkId, ctx); errorCode(appErr) != tt.wantView {
+				t.Errorf("GetLinkByID() code = %d, want %d", errorCode(appErr), tt.wantView)
+			}
+
+			update := domain.UpdateLinkDTO{Name: "renamed by " + tt.userId}
+			if _, appErr := service.UpdateLinkByID(tt.linkId, &update, ctx); errorCode(appErr) != tt.wantUpdate {
+				t.Errorf("UpdateLinkByID() code = %d, want %d", errorCode(appErr), tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func errorCode(appErr *errs.AppError) int {
+	if appErr == nil {
+		return 0
+	}
+
+	return appErr.Code
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+type passwordAttempt struct {
+	failures    int
+	windowStart time.Time
+}
+
+// PasswordAttempts counts wrong passwords per key in fixed windows. A key is
+// blocked once it reaches the limit, until its window is over.
+type PasswordAttempts struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string]*passwordAttempt
+	lastSweep time.Time
+}
+
+// Blocked returns how long the key has to wait before the next attempt.
+func (a *PasswordAttempts) Blocked(key string, now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	attempt, ok := a.attempts[key]
+	if !ok || attempt.failures < a.limit {
+		return 0
+	}
+
+	wait := attempt.windowStart.Add(a.window).Sub(now)
+	if wait <= 0 {
+		delete(a.attempts, key)
+		return 0
+	}
+
+	return wait
+}
+
+func (a *PasswordAttempts) Fail(key string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.removeExpired(now)
+
+	attempt, ok := a.attempts[key]
+	if !ok || now.Sub(attempt.windowStart) >= a.window {
+		attempt = &passwordAttempt{windowStart: now}
+		a.attempts[key] = attempt
+	}
+
+	attempt.failures++
+}
+
+func (a *PasswordAttempts) Reset(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.attempts, key)
+}
+
+// removeExpired keeps the map from growing with keys that won't be blocked anymore.
+// It runs at most once per window.
+func (a *PasswordAttempts) removeExpired(now time.Time) {
+	if now.Sub(a.lastSweep) < a.window {
+		return
+	}
+
+	a.lastSweep = now
+
+	for key, attempt := range a.attempts {
+		if now.Sub(attempt.windowStart) >= a.window {
+			delete(a.attempts, key)
+		}
+	}
+}
+
+func NewPasswordAttempts(limit int, window time.Duration) *PasswordAttempts {
+	return &PasswordAttempts{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string]*passwordAttempt),
+	}
+}
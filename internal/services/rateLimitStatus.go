@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/mennanov/limiters"
+)
+
+// RateLimitStatus is the quota of a client after a request. The limiters
+// library only reports how long to wait, so the backends of every algorithm
+// are wrapped and record what they return into the status of the request context.
+type RateLimitStatus struct {
+	Limit      int64
+	Remaining  int64
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+func withRateLimitStatus(ctx context.Context, status *RateLimitStatus) context.Context {
+	return context.WithValue(ctx, "RateLimitStatus", status)
+}
+
+func recordRateLimitStatus(ctx context.Context, remaining int64, reset time.Duration) {
+	status, ok := ctx.Value("RateLimitStatus").(*RateLimitStatus)
+	if !ok {
+		return
+	}
+
+	status.Remaining = max(remaining, 0)
+	status.Reset = reset
+}
+
+type statusFixedWindow struct {
+	backend limiters.FixedWindowIncrementer
+	policy  RateLimitPolicy
+}
+
+func (w statusFixedWindow) Increment(ctx context.Context, window time.Time, ttl time.Duration) (int64, error) {
+	count, err := w.backend.Increment(ctx, window, ttl)
+	if err == nil {
+		recordRateLimitStatus(ctx, w.policy.Capacity-count, ttl)
+	}
+
+	return count, err
+}
+
+// The sliding window counts the previous window in proportion to the part of it that's still inside the window
+type statusSlidingWindow struct {
+	backend limiters.SlidingWindowIncrementer
+	policy  RateLimitPolicy
+}
+
+func (w statusSlidingWindow) Increment(ctx context.Context, prev time.Time, curr time.Time, ttl time.Duration) (int64, int64, error) {
+	prevCount, currCount, err := w.backend.Increment(ctx, prev, curr, ttl)
+	if err == nil {
+		reset := ttl - w.policy.Window
+		used := float64(prevCount)*float64(reset)/float64(w.policy.Window) + float64(currCount)
+		recordRateLimitStatus(ctx, w.policy.Capacity-int64(math.Ceil(used)), reset)
+	}
+
+	return prevCount, currCount, err
+}
+
+// The token bucket doesn't store its state when the bucket is empty, so the
+// refill is repeated here when the state is read, the same way the bucket does it.
+type statusTokenBucket struct {
+	limiters.TokenBucketStateBackend
+	policy     RateLimitPolicy
+	refillRate time.Duration
+}
+
+func (b statusTokenBucket) State(ctx context.Context) (limiters.TokenBucketState, error) {
+	state, err := b.TokenBucketStateBackend.State(ctx)
+	if err != nil {
+		return state, err
+	}
+
+	available := b.policy.Capacity
+	if state.Last != 0 || state.Available != 0 {
+		refilled := (time.Now().UnixNano() - state.Last) / int64(b.refillRate)
+		available = min(state.Available+max(refilled, 0), b.policy.Capacity)
+	}
+
+	b.record(ctx, available)
+	return state, nil
+}
+
+func (b statusTokenBucket) SetState(ctx context.Context, state limiters.TokenBucketState) error {
+	if err := b.TokenBucketStateBackend.SetState(ctx, state); err != nil {
+		return err
+	}
+
+	b.record(ctx, state.Available)
+	return nil
+}
+
+// The bucket is reset once it's full again
+func (b statusTokenBucket) record(ctx context.Context, available int64) {
+	recordRateLimitStatus(ctx, available, time.Duration(b.policy.Capacity-available)*b.refillRate)
+}
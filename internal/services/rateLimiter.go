@@ -1,7 +1,13 @@
 package services
 
 import (
+	"container/list"
 	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mennanov/limiters"
@@ -11,14 +17,133 @@ type RateLimiter interface {
 	Limit(context context.Context) (time.Duration, error)
 }
 
-func NewRateLimiter(capacity int64, rate time.Duration) RateLimiter {
-	limiter := limiters.NewSlidingWindow(
-		capacity,
-		rate,
-		limiters.NewSlidingWindowInMemory(),
-		limiters.NewSystemClock(),
-		0.01,
-	)
+// RateLimitPolicy allows Capacity requests per Window for every client.
+type RateLimitPolicy struct {
+	Capacity int64
+	Window   time.Duration
+}
+
+// ParseRateLimitPolicy reads a policy written as "<capacity>/<window>", e.g. "60/1m".
+func ParseRateLimitPolicy(value string) (RateLimitPolicy, error) {
+	capacity, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimitPolicy{}, fmt.Errorf("invalid rate limit policy %q, expected <capacity>/<window>", value)
+	}
+
+	policy := RateLimitPolicy{}
+
+	parsedCapacity, err := strconv.ParseInt(strings.TrimSpace(capacity), 10, 64)
+	if err != nil || parsedCapacity <= 0 {
+		return policy, fmt.Errorf("invalid rate limit capacity %q", capacity)
+	}
+
+	parsedWindow, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || parsedWindow <= 0 {
+		return policy, fmt.Errorf("invalid rate limit window %q", window)
+	}
+
+	policy.Capacity = parsedCapacity
+	policy.Window = parsedWindow
+
+	return policy, nil
+}
+
+type keyedLimiter struct {
+	key      string
+	limiter  RateLimiter
+	lastUsed time.Time
+}
+
+// KeyedRateLimiter keeps a separate limiter for every client key. The least
+// recently used keys are evicted once there are more than maxKeys of them, and
+// keys idle for longer than two windows are dropped. With a shared backend the
+// state outlives the eviction, only the local limiter object is dropped.
+// The name separates the keys of different limiters in a shared backend.
+type KeyedRateLimiter struct {
+	name    string
+	policy  RateLimitPolicy
+	maxKeys int
+	factory RateLimiterFactory
+
+	mu       sync.Mutex
+	lru      *list.List
+	limiters map[string]*list.Element
+}
+
+// Limit returns the quota left for the key, together with limiters.ErrLimitExhausted when there's none.
+// It retries a few times when a distributed backend reports a concurrent update of the same key.
+func (l *KeyedRateLimiter) Limit(key string, ctx context.Context) (*RateLimitStatus, error) {
+	limiter := l.limiterFor(key, time.Now())
+
+	status := &RateLimitStatus{Limit: l.policy.Capacity, Remaining: l.policy.Capacity}
+	ctx = withRateLimitStatus(ctx, status)
+
+	var wait time.Duration
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		wait, err = limiter.Limit(ctx)
+		if !errors.Is(err, limiters.ErrRaceCondition) {
+			break
+		}
+	}
+
+	// A fixed window that has already overflowed doesn't reach its backend
+	if err == limiters.ErrLimitExhausted {
+		status.Remaining = 0
+		status.RetryAfter = wait
+		status.Reset = max(status.Reset, wait)
+	}
+
+	return status, err
+}
+
+func (l *KeyedRateLimiter) limiterFor(key string, now time.Time) RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evictIdle(now)
+
+	if element, ok := l.limiters[key]; ok {
+		entry := element.Value.(*keyedLimiter)
+		entry.lastUsed = now
+		l.lru.MoveToFront(element)
+
+		return entry.limiter
+	}
+
+	entry := &keyedLimiter{key: key, limiter: l.factory(l.name+":"+key, l.policy), lastUsed: now}
+	l.limiters[key] = l.lru.PushFront(entry)
+
+	for l.lru.Len() > l.maxKeys {
+		l.remove(l.lru.Back())
+	}
+
+	return entry.limiter
+}
+
+// evictIdle walks from the least recently used end, so it stops at the first active key
+func (l *KeyedRateLimiter) evictIdle(now time.Time) {
+	for element := l.lru.Back(); element != nil; element = l.lru.Back() {
+		if now.Sub(element.Value.(*keyedLimiter).lastUsed) < 2*l.policy.Window {
+			return
+		}
+
+		l.remove(element)
+	}
+}
+
+func (l *KeyedRateLimiter) remove(element *list.Element) {
+	l.lru.Remove(element)
+	delete(l.limiters, element.Value.(*keyedLimiter).key)
+}
 
-	return limiter
+func NewKeyedRateLimiter(name string, policy RateLimitPolicy, maxKeys int, factory RateLimiterFactory) *KeyedRateLimiter {
+	return &KeyedRateLimiter{
+		name:     name,
+		policy:   policy,
+		maxKeys:  maxKeys,
+		factory:  factory,
+		lru:      list.New(),
+		limiters: make(map[string]*list.Element),
+	}
 }
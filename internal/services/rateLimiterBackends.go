@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/bradfitz/gomemcache/memcache"
+	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
+	"github.com/guregu/dynamo/v2"
+	"github.com/mennanov/limiters"
+	"github.com/redis/go-redis/v9"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type RateLimitBackend string
+
+const (
+	MemoryRateLimitBackend    RateLimitBackend = "memory"
+	RedisRateLimitBackend     RateLimitBackend = "redis"
+	MemcachedRateLimitBackend RateLimitBackend = "memcached"
+	DynamoDBRateLimitBackend  RateLimitBackend = "dynamodb"
+	PostgresRateLimitBackend  RateLimitBackend = "postgres"
+	EtcdRateLimitBackend      RateLimitBackend = "etcd"
+)
+
+type RateLimitAlgorithm string
+
+const (
+	SlidingWindowAlgorithm RateLimitAlgorithm = "sliding_window"
+	TokenBucketAlgorithm   RateLimitAlgorithm = "token_bucket"
+	FixedWindowAlgorithm   RateLimitAlgorithm = "fixed_window"
+)
+
+// Algorithms every backend can run. The limiters library keeps only token
+// buckets in etcd, and Postgres is backed by our own window counters.
+var rateLimitBackendAlgorithms = map[RateLimitBackend][]RateLimitAlgorithm{
+	MemoryRateLimitBackend:    {SlidingWindowAlgorithm, TokenBucketAlgorithm, FixedWindowAlgorithm},
+	RedisRateLimitBackend:     {SlidingWindowAlgorithm, TokenBucketAlgorithm, FixedWindowAlgorithm},
+	MemcachedRateLimitBackend: {SlidingWindowAlgorithm, TokenBucketAlgorithm, FixedWindowAlgorithm},
+	DynamoDBRateLimitBackend:  {SlidingWindowAlgorithm, TokenBucketAlgorithm, FixedWindowAlgorithm},
+	PostgresRateLimitBackend:  {SlidingWindowAlgorithm, FixedWindowAlgorithm},
+	EtcdRateLimitBackend:      {TokenBucketAlgorithm},
+}
+
+const rateLimitsTableName = "RateLimits"
+
+// The limiters library reads the key names and the TTL attribute from the table description
+type rateLimitRecord struct {
+	PK string `dynamo:"PK,hash"`
+	SK string `dynamo:"SK,range"`
+}
+
+// RateLimiterOptions selects where the limiter state is kept. Address is a
+// Redis URL, a comma separated list of Memcached servers or etcd endpoints,
+// or a Postgres connection string. DynamoDB uses the DynamoDB of the service.
+type RateLimiterOptions struct {
+	Backend   RateLimitBackend
+	Algorithm RateLimitAlgorithm
+	Address   string
+	Prefix    string
+}
+
+// RateLimiterFactory creates the limiter of a single client key.
+type RateLimiterFactory func(key string, policy RateLimitPolicy) RateLimiter
+
+type limiterLogger struct{}
+
+func (limiterLogger) Log(v ...interface{}) {
+	utils.Logger.Warn(v...)
+}
+
+// Validate fills in the defaults of empty options and checks that the backend can run the algorithm.
+func (o *RateLimiterOptions) Validate() error {
+	if o.Backend == "" {
+		o.Backend = MemoryRateLimitBackend
+	}
+
+	if o.Algorithm == "" {
+		o.Algorithm = SlidingWindowAlgorithm
+	}
+
+	if o.Prefix == "" {
+		o.Prefix = "ratelimit"
+	}
+
+	algorithms, ok := rateLimitBackendAlgorithms[o.Backend]
+	if !ok {
+		return fmt.Errorf("unknown rate limit backend %q", o.Backend)
+	}
+
+	supported := false
+	for _, algorithm := range algorithms {
+		supported = supported || algorithm == o.Algorithm
+	}
+
+	if !supported {
+		return fmt.Errorf("rate limit backend %s doesn't support the %s algorithm", o.Backend, o.Algorithm)
+	}
+
+	if o.Backend != MemoryRateLimitBackend && o.Backend != DynamoDBRateLimitBackend && o.Address == "" {
+		return fmt.Errorf("rate limit backend %s needs an address", o.Backend)
+	}
+
+	return nil
+}
+
+// NewRateLimiterFactory connects to the backend once, the limiters of all keys share the connection.
+func NewRateLimiterFactory(options RateLimiterOptions, db *dynamo.DB) (RateLimiterFactory, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch options.Backend {
+	case RedisRateLimitBackend:
+		return newRedisRateLimiterFactory(options)
+	case MemcachedRateLimitBackend:
+		return newMemcachedRateLimiterFactory(options), nil
+	case DynamoDBRateLimitBackend:
+		return newDynamoDBRateLimiterFactory(options, db)
+	case PostgresRateLimitBackend:
+		return newPostgresRateLimiterFactory(options)
+	case EtcdRateLimitBackend:
+		return newEtcdRateLimiterFactory(options)
+	default:
+		return newMemoryRateLimiterFactory(options), nil
+	}
+}
+
+func newMemoryRateLimiterFactory(options RateLimiterOptions) RateLimiterFactory {
+	return func(key string, policy RateLimitPolicy) RateLimiter {
+		switch options.Algorithm {
+		case TokenBucketAlgorithm:
+			return newTokenBucket(policy, limiters.NewLockNoop(), limiters.NewTokenBucketInMemory())
+		case FixedWindowAlgorithm:
+			return newFixedWindow(policy, limiters.NewFixedWindowInMemory())
+		default:
+			return newSlidingWindow(policy, limiters.NewSlidingWindowInMemory())
+		}
+	}
+}
+
+func newRedisRateLimiterFactory(options RateLimiterOptions) (RateLimiterFactory, error) {
+	redisOptions, err := redis.ParseURL(options.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis address: %w", err)
+	}
+
+	client := redis.NewClient(redisOptions)
+	pool := goredis.NewPool(client)
+
+	return func(key string, policy RateLimitPolicy) RateLimiter {
+		prefix := options.Prefix + ":" + key
+
+		switch options.Algorithm {
+		case TokenBucketAlgorithm:
+			locker := limiters.NewLockRedis(pool, prefix+":lock")
+			return newTokenBucket(policy, locker, limiters.NewTokenBucketRedis(client, prefix, 2*policy.Window, false))
+		case FixedWindowAlgorithm:
+			return newFixedWindow(policy, limiters.NewFixedWindowRedis(client, prefix))
+		default:
+			return newSlidingWindow(policy, limiters.NewSlidingWindowRedis(client, prefix))
+		}
+	}, nil
+}
+
+func newMemcachedRateLimiterFactory(options RateLimiterOptions) RateLimiterFactory {
+	client := memcache.New(splitAddresses(options.Address)...)
+
+	return func(key string, policy RateLimitPolicy) RateLimiter {
+		prefix := options.Prefix + ":" + key
+
+		switch options.Algorithm {
+		case TokenBucketAlgorithm:
+			locker := limiters.NewLockMemcached(client, prefix+":lock")
+			return newTokenBucket(policy, locker, limiters.NewTokenBucketMemcached(client, prefix, 2*policy.Window, false))
+		case FixedWindowAlgorithm:
+			return newFixedWindow(policy, limiters.NewFixedWindowMemcached(client, prefix))
+		default:
+			return newSlidingWindow(policy, limiters.NewSlidingWindowMemcached(client, prefix))
+		}
+	}
+}
+
+// The DynamoDB token bucket has no lock, conflicting updates are detected
+// with the race check instead and retried by KeyedRateLimiter.
+func newDynamoDBRateLimiterFactory(options RateLimiterOptions, db *dynamo.DB) (RateLimiterFactory, error) {
+	if db == nil {
+		return nil, errors.New("rate limit backend dynamodb needs DynamoDB")
+	}
+
+	client, ok := db.Client().(*dynamodb.Client)
+	if !ok {
+		return nil, errors.New("rate limit backend dynamodb needs a DynamoDB client")
+	}
+
+	table := GetOrCreateTable(db, rateLimitsTableName, rateLimitRecord{})
+
+	ttl, err := table.DescribeTTL().Run(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	if !ttl.Enabled() {
+		if err := table.UpdateTTL("TTL", true).Run(context.TODO()); err != nil {
+			return nil, err
+		}
+	}
+
+	props, err := limiters.LoadDynamoDBTableProperties(context.TODO(), client, rateLimitsTableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The TTL attribute isn't reported while TTL is still being enabled
+	if props.TTLFieldName == "" {
+		props.TTLFieldName = "TTL"
+	}
+
+	return func(key string, policy RateLimitPolicy) RateLimiter {
+		partitionKey := options.Prefix + ":" + key
+
+		switch options.Algorithm {
+		case TokenBucketAlgorithm:
+			backend := limiters.NewTokenBucketDynamoDB(client, partitionKey, props, 2*policy.Window, true)
+			return newTokenBucket(policy, limiters.NewLockNoop(), backend)
+		case FixedWindowAlgorithm:
+			backend := limiters.NewFixedWindowDynamoDB(client, partitionKey, props)
+			return newFixedWindow(policy, backend)
+		default:
+			return newSlidingWindow(policy, limiters.NewSlidingWindowDynamoDB(client, partitionKey, props))
+		}
+	}, nil
+}
+
+func newPostgresRateLimiterFactory(options RateLimiterOptions) (RateLimiterFactory, error) {
+	counters, err := NewPostgresWindowCounters(options.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(key string, policy RateLimitPolicy) RateLimiter {
+		prefix := options.Prefix + ":" + key
+
+		if options.Algorithm == FixedWindowAlgorithm {
+			return newFixedWindow(policy, counters.FixedWindow(prefix))
+		}
+
+		return newSlidingWindow(policy, counters.SlidingWindow(prefix))
+	}, nil
+}
+
+func newEtcdRateLimiterFactory(options RateLimiterOptions) (RateLimiterFactory, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   splitAddresses(options.Address),
+		DialTimeout: time.Second * 5,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func(key string, policy RateLimitPolicy) RateLimiter {
+		prefix := "/" + options.Prefix + "/" + key
+
+		locker := limiters.NewLockEtcd(client, prefix+"/lock", limiterLogger{})
+		return newTokenBucket(policy, locker, limiters.NewTokenBucketEtcd(client, prefix, 2*policy.Window, false))
+	}, nil
+}
+
+func newSlidingWindow(policy RateLimitPolicy, backend limiters.SlidingWindowIncrementer) RateLimiter {
+	backend = statusSlidingWindow{backend: backend, policy: policy}
+
+	return limiters.NewSlidingWindow(policy.Capacity, policy.Window, backend, limiters.NewSystemClock(), 0.01)
+}
+
+func newFixedWindow(policy RateLimitPolicy, backend limiters.FixedWindowIncrementer) RateLimiter {
+	backend = statusFixedWindow{backend: backend, policy: policy}
+
+	return limiters.NewFixedWindow(policy.Capacity, policy.Window, backend, limiters.NewSystemClock())
+}
+
+// The bucket holds Capacity tokens and refills all of them over one window
+func newTokenBucket(policy RateLimitPolicy, locker limiters.DistLocker, backend limiters.TokenBucketStateBackend) RateLimiter {
+	refillRate := policy.Window / time.Duration(policy.Capacity)
+	backend = statusTokenBucket{TokenBucketStateBackend: backend, policy: policy, refillRate: refillRate}
+
+	return limiters.NewTokenBucket(policy.Capacity, refillRate, locker, backend, limiters.NewSystemClock(), limiterLogger{})
+}
+
+func splitAddresses(value string) []string {
+	var addresses []string
+	for _, address := range strings.Split(value, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
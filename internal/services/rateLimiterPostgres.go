@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const createRateLimitsTable = `CREATE TABLE IF NOT EXISTS rate_limits (
+	key TEXT NOT NULL,
+	window_start BIGINT NOT NULL,
+	count BIGINT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (key, window_start)
+)`
+
+const incrementRateLimitWindow = `INSERT INTO rate_limits (key, window_start, count, expires_at)
+VALUES ($1, $2, 1, $3)
+ON CONFLICT (key, window_start) DO UPDATE SET count = rate_limits.count + 1, expires_at = EXCLUDED.expires_at
+RETURNING count`
+
+const selectRateLimitWindow = `SELECT count FROM rate_limits WHERE key = $1 AND window_start = $2 AND expires_at > NOW()`
+
+const deleteExpiredRateLimits = `DELETE FROM rate_limits WHERE expires_at < NOW()`
+
+// PostgresWindowCounters keeps the counters of fixed and sliding windows in
+// Postgres, as the limiters library only ships a Postgres lock.
+type PostgresWindowCounters struct {
+	db *sql.DB
+}
+
+// postgresFixedWindow implements limiters.FixedWindowIncrementer for one key.
+type postgresFixedWindow struct {
+	counters *PostgresWindowCounters
+	key      string
+}
+
+// postgresSlidingWindow implements limiters.SlidingWindowIncrementer for one key.
+type postgresSlidingWindow struct {
+	counters *PostgresWindowCounters
+	key      string
+}
+
+func (c *PostgresWindowCounters) FixedWindow(key string) *postgresFixedWindow {
+	return &postgresFixedWindow{counters: c, key: key}
+}
+
+func (c *PostgresWindowCounters) SlidingWindow(key string) *postgresSlidingWindow {
+	return &postgresSlidingWindow{counters: c, key: key}
+}
+
+func (w *postgresFixedWindow) Increment(ctx context.Context, window time.Time, ttl time.Duration) (int64, error) {
+	return w.counters.increment(w.key, window, ttl, ctx)
+}
+
+func (w *postgresSlidingWindow) Increment(ctx context.Context, prev time.Time, curr time.Time, ttl time.Duration) (int64, int64, error) {
+	currCount, err := w.counters.increment(w.key, curr, ttl, ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var prevCount int64
+	err = w.counters.db.QueryRowContext(ctx, selectRateLimitWindow, w.key, prev.UnixNano()).Scan(&prevCount)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, err
+	}
+
+	return prevCount, currCount, nil
+}
+
+func (c *PostgresWindowCounters) increment(key string, window time.Time, ttl time.Duration, ctx context.Context) (int64, error) {
+	var count int64
+
+	err := c.db.QueryRowContext(ctx, incrementRateLimitWindow, key, window.UnixNano(), time.Now().Add(ttl)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	// Expired windows are removed now and then instead of by a separate job
+	if rand.Intn(1000) == 0 {
+		if _, err := c.db.ExecContext(ctx, deleteExpiredRateLimits); err != nil {
+			utils.Logger.Error("Error while deleting expired rate limits", zap.Error(err))
+		}
+	}
+
+	return count, nil
+}
+
+func NewPostgresWindowCounters(dsn string) (*PostgresWindowCounters, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.ExecContext(context.TODO(), createRateLimitsTable); err != nil {
+		return nil, err
+	}
+
+	return &PostgresWindowCounters{db: db}, nil
+}
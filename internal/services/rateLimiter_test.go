@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    RateLimitPolicy
+		wantErr bool
+	}{
+		{value: "60/1m", want: RateLimitPolicy{Capacity: 60, Window: time.Minute}},
+		{value: " 10 / 30s ", want: RateLimitPolicy{Capacity: 10, Window: 30 * time.Second}},
+		{value: "1/1h30m", want: RateLimitPolicy{Capacity: 1, Window: 90 * time.Minute}},
+		{value: "60", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "0/1m", wantErr: true},
+		{value: "-5/1m", wantErr: true},
+		{value: "abc/1m", wantErr: true},
+		{value: "60/0s", wantErr: true},
+		{value: "60/-1m", wantErr: true},
+		{value: "60/minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRateLimitPolicy(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRateLimitPolicy(%q) error = %v, wantErr %t", tt.value, err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseRateLimitPolicy(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// RedirectCounter counts redirects of links. Flush writes pending increments
+// to the storage, if the implementation keeps any. Close flushes them for the last time.
+type RedirectCounter interface {
+	Increment(id string, counter LinkCounter, ctx context.Context) error
+	Flush(ctx context.Context) error
+	Close(ctx context.Context) error
+}
+
+// DirectRedirectCounter writes every increment to the repository right away.
+type DirectRedirectCounter struct {
+	repo LinkRepository
+}
+
+func (c *DirectRedirectCounter) Increment(id string, counter LinkCounter, ctx context.Context) error {
+	return c.repo.IncrementCounter(id, counter, 1, ctx)
+}
+
+func (c *DirectRedirectCounter) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (c *DirectRedirectCounter) Close(ctx context.Context) error {
+	return nil
+}
+
+func NewDirectRedirectCounter(repo LinkRepository) *DirectRedirectCounter {
+	return &DirectRedirectCounter{repo: repo}
+}
+
+type redirectCounterKey struct {
+	id      string
+	counter LinkCounter
+}
+
+// BufferedRedirectCounter sums increments in memory and writes them in batches
+// every interval. Increments that were not flushed yet are lost if the process
+// dies, so Flush must be called before shutting down.
+type BufferedRedirectCounter struct {
+	repo     LinkRepository
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[redirectCounterKey]int
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (c *BufferedRedirectCounter) Increment(id string, counter LinkCounter, ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending[redirectCounterKey{id, counter}]++
+	return nil
+}
+
+func (c *BufferedRedirectCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[redirectCounterKey]int)
+	c.mu.Unlock()
+
+	var flushErr error
+
+	for key, delta := range pending {
+		err := c.repo.IncrementCounter(key.id, key.counter, delta, ctx)
+		if err == nil || err == ErrLinkNotFound {
+			continue
+		}
+
+		utils.Logger.Error("Error while flushing redirect counter", zap.String("linkID", key.id), zap.Error(err))
+		flushErr = err
+
+		// Keep the increment for the next flush
+		c.mu.Lock()
+		c.pending[key] += delta
+		c.mu.Unlock()
+	}
+
+	return flushErr
+}
+
+// Close stops the background flushing and writes the remaining increments.
+func (c *BufferedRedirectCounter) Close(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return c.Flush(ctx)
+}
+
+func (c *BufferedRedirectCounter) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush(context.Background())
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func NewBufferedRedirectCounter(repo LinkRepository, interval time.Duration) *BufferedRedirectCounter {
+	counter := &BufferedRedirectCounter{
+		repo:     repo,
+		interval: interval,
+		pending:  make(map[redirectCounterKey]int),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go counter.run()
+
+	return counter
+}
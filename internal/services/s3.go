@@ -2,20 +2,37 @@ package services
 
 import (
 	"context"
-	"os"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/the-redx/link-shortener/pkg/utils"
 )
 
-const LINK_ATTACHMENTS_BUCKET = "links-attachments"
+// Presigned attachment URLs are made on every click, so they only need to live until the browser follows the redirect
+const attachmentUrlExpiry = time.Minute * 5
 
-func NewS3Service() *s3.Client {
-	environment := os.Getenv("APP_ENV")
-	s3Endpoint := os.Getenv("S3_ENDPOINT")
+// S3Options point to the bucket of attachments. Endpoint and UsePathStyle are
+// for S3-compatible storages and local stacks. When PublicUrl is set, e.g. to a
+// CloudFront distribution in front of the bucket, attachment URLs are made by
+// prefixing the object keys with it instead of being presigned.
+//
+// Such URLs don't expire and aren't tied to the password or the limits of the
+// link, so the CDN must enforce access itself, e.g. with CloudFront signed URLs
+// or cookies. It must also pass the response-content-disposition query on to the
+// bucket through origin access control, S3 only honors it on signed requests.
+type S3Options struct {
+	Bucket       string
+	Region       string
+	Endpoint     string
+	UsePathStyle bool
+	PublicUrl    string
+}
 
-	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion("eu-north-1"))
+func NewS3Service(options S3Options) *s3.Client {
+	cfg, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(options.Region))
 	if err != nil {
 		utils.Logger.Fatal("Error loading AWS config")
 	}
@@ -23,10 +40,21 @@ func NewS3Service() *s3.Client {
 	utils.Logger.Debug("S3 config created")
 
 	return s3.NewFromConfig(cfg, func(o *s3.Options) {
-		if environment == "development" && s3Endpoint != "" {
-			utils.Logger.Debugf("S3 base endpoint is set to %s", s3Endpoint)
-			o.UsePathStyle = true
-			o.BaseEndpoint = &s3Endpoint
+		o.UsePathStyle = options.UsePathStyle
+
+		if options.Endpoint != "" {
+			utils.Logger.Debugf("S3 base endpoint is set to %s", options.Endpoint)
+			o.BaseEndpoint = &options.Endpoint
 		}
 	})
 }
+
+// publicObjectUrl escapes every segment of the key, the base URL may have a path of its own
+func publicObjectUrl(baseUrl string, key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+
+	return strings.TrimSuffix(baseUrl, "/") + "/" + strings.Join(segments, "/")
+}
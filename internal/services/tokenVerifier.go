@@ -0,0 +1,303 @@
+package services
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// The JWKS file is checked for changes at most once per interval
+const jwksReloadInterval = time.Second * 10
+
+var (
+	ErrNoTokenKey       = errors.New("no key for the token")
+	ErrInvalidUserClaim = errors.New("token has no valid user claim")
+)
+
+// TokenVerifierOptions describes how JWTs are checked. Secret verifies HS256
+// tokens, the JWKS file holds the RSA and EC keys and may hold HMAC keys too.
+// Audience and Issuer are checked only when they are set.
+type TokenVerifierOptions struct {
+	Secret    string
+	JWKSFile  string
+	Audience  string
+	Issuer    string
+	UserClaim string
+}
+
+type jwksFile struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+type tokenKey struct {
+	kid string
+	key any
+}
+
+// TokenVerifier validates HS256, RS256 and ES256 tokens with local keys only.
+type TokenVerifier struct {
+	options TokenVerifierOptions
+	parser  *jwt.Parser
+
+	mu          sync.RWMutex
+	keys        []tokenKey
+	fileModTime time.Time
+	fileSize    int64
+	lastCheck   time.Time
+}
+
+// Verify checks the signature and the registered claims of the token and
+// returns the user ID from the user claim.
+func (v *TokenVerifier) Verify(token string) (string, error) {
+	v.reloadIfChanged()
+
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyFor); err != nil {
+		return "", err
+	}
+
+	userId, ok := claims[v.options.UserClaim].(string)
+	if !ok || userId == "" {
+		return "", ErrInvalidUserClaim
+	}
+
+	return userId, nil
+}
+
+// keyFor picks the keys matching the algorithm of the token. Keys of another
+// type are never used, so an RSA public key can't be passed off as an HMAC secret.
+func (v *TokenVerifier) keyFor(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	var keySet jwt.VerificationKeySet
+
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && v.options.Secret != "" {
+		keySet.Keys = append(keySet.Keys, []byte(v.options.Secret))
+	}
+
+	v.mu.RLock()
+	for _, key := range v.keys {
+		if kid != "" && key.kid != kid {
+			continue
+		}
+
+		switch token.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if secret, ok := key.key.([]byte); ok {
+				keySet.Keys = append(keySet.Keys, secret)
+			}
+		case *jwt.SigningMethodRSA:
+			if publicKey, ok := key.key.(*rsa.PublicKey); ok {
+				keySet.Keys = append(keySet.Keys, publicKey)
+			}
+		case *jwt.SigningMethodECDSA:
+			if publicKey, ok := key.key.(*ecdsa.PublicKey); ok {
+				keySet.Keys = append(keySet.Keys, publicKey)
+			}
+		}
+	}
+	v.mu.RUnlock()
+
+	if len(keySet.Keys) == 0 {
+		return nil, ErrNoTokenKey
+	}
+
+	return keySet, nil
+}
+
+// reloadIfChanged reads the JWKS file again when its modification time or size
+// changes. The previous keys are kept if the new file is invalid.
+func (v *TokenVerifier) reloadIfChanged() {
+	if v.options.JWKSFile == "" {
+		return
+	}
+
+	v.mu.Lock()
+	if time.Since(v.lastCheck) < jwksReloadInterval {
+		v.mu.Unlock()
+		return
+	}
+	v.lastCheck = time.Now()
+	v.mu.Unlock()
+
+	info, err := os.Stat(v.options.JWKSFile)
+	if err != nil {
+		utils.Logger.Error("Error while checking the JWKS file", zap.Error(err))
+		return
+	}
+
+	v.mu.RLock()
+	changed := !info.ModTime().Equal(v.fileModTime) || info.Size() != v.fileSize
+	v.mu.RUnlock()
+
+	if !changed {
+		return
+	}
+
+	if err := v.loadKeys(); err != nil {
+		utils.Logger.Error("Error while reloading the JWKS file. Keep the previous keys", zap.Error(err))
+		return
+	}
+
+	utils.Logger.Info("JWKS file reloaded")
+}
+
+func (v *TokenVerifier) loadKeys() error {
+	info, err := os.Stat(v.options.JWKSFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(v.options.JWKSFile)
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("invalid JWKS file %s: %w", v.options.JWKSFile, err)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.keys = keys
+	v.fileModTime = info.ModTime()
+	v.fileSize = info.Size()
+
+	return nil
+}
+
+func parseJWKS(data []byte) ([]tokenKey, error) {
+	var file jwksFile
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	keys := make([]tokenKey, 0, len(file.Keys))
+
+	for i, key := range file.Keys {
+		// Encryption keys can't verify signatures
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		publicKey, err := parseJWK(&key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+
+		keys = append(keys, tokenKey{kid: key.Kid, key: publicKey})
+	}
+
+	return keys, nil
+}
+
+func parseJWK(key *jwk) (any, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid exponent")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if key.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+
+		x, errX := base64.RawURLEncoding.DecodeString(key.X)
+		y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid coordinates")
+		}
+
+		// ecdh rejects points that aren't on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(key.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("invalid secret")
+		}
+
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", key.Kty)
+	}
+}
+
+func NewTokenVerifier(options TokenVerifierOptions) (*TokenVerifier, error) {
+	if options.Secret == "" && options.JWKSFile == "" {
+		return nil, errors.New("either a secret or a JWKS file is required")
+	}
+
+	if options.UserClaim == "" {
+		options.UserClaim = "sub"
+	}
+
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256", "ES256"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Second * 30),
+	}
+
+	if options.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(options.Audience))
+	}
+
+	if options.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(options.Issuer))
+	}
+
+	verifier := &TokenVerifier{
+		options:   options,
+		parser:    jwt.NewParser(parserOptions...),
+		lastCheck: time.Now(),
+	}
+
+	if options.JWKSFile != "" {
+		if err := verifier.loadKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	return verifier, nil
+}
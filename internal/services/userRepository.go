@@ -0,0 +1,19 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrUserAlreadyExists = errors.New("user already exists")
+)
+
+type UserRepository interface {
+	Get(id string, ctx context.Context) (*domain.User, error)
+	// Put fails with ErrUserAlreadyExists if a user with the same ID exists.
+	Put(user *domain.User, ctx context.Context) error
+}
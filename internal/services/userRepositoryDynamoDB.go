@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+
+	"github.com/guregu/dynamo/v2"
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+type DynamoDBUserRepository struct {
+	usersTable dynamo.Table
+}
+
+func (r *DynamoDBUserRepository) Get(id string, ctx context.Context) (*domain.User, error) {
+	var user domain.User
+
+	if err := r.usersTable.Get("ID", id).One(ctx, &user); err != nil {
+		if err == dynamo.ErrNotFound {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *DynamoDBUserRepository) Put(user *domain.User, ctx context.Context) error {
+	err := r.usersTable.Put(user).If("attribute_not_exists('ID')").Run(ctx)
+	if dynamo.IsCondCheckFailed(err) {
+		return ErrUserAlreadyExists
+	}
+
+	return err
+}
+
+func NewDynamoDBUserRepository(db *dynamo.DB) *DynamoDBUserRepository {
+	table := GetOrCreateTable(db, "Users", domain.User{})
+
+	return &DynamoDBUserRepository{usersTable: table}
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+// MemoryUserRepository keeps users in process memory.
+type MemoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]domain.User
+}
+
+func (r *MemoryUserRepository) Get(id string, ctx context.Context) (*domain.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return &user, nil
+}
+
+func (r *MemoryUserRepository) Put(user *domain.User, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; ok {
+		return ErrUserAlreadyExists
+	}
+
+	r.users[user.ID] = *user
+	return nil
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: make(map[string]domain.User)}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/the-redx/link-shortener/pkg/hll"
+	"github.com/the-redx/link-shortener/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// VisitorCounter collects visitor hashes into per link and day sketches and
+// merges them into the stats repository every interval, together with the
+// all-time sketch of the link.
+type VisitorCounter struct {
+	repo     LinkStatsRepository
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[string]map[string]*hll.Sketch
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (c *VisitorCounter) Add(linkId string, timestamp time.Time, visitorHash uint64) {
+	day := timestamp.UTC().Format(statsDayFormat)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pendingSketch(linkId, day).Add(visitorHash)
+}
+
+func (c *VisitorCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]map[string]*hll.Sketch)
+	c.mu.Unlock()
+
+	var flushErr error
+
+	for linkId, days := range pending {
+		total := hll.New()
+
+		for day, sketch := range days {
+			total.Merge(sketch)
+
+			// A requeued all-time sketch is written together with the days below
+			if day == TotalStatsPeriod {
+				continue
+			}
+
+			if err := c.repo.MergeVisitors(linkId, day, sketch, ctx); err != nil {
+				utils.Logger.Error("Error while flushing daily visitors", zap.String("linkID", linkId), zap.Error(err))
+				flushErr = err
+				c.requeue(linkId, day, sketch)
+			}
+		}
+
+		if err := c.repo.MergeVisitors(linkId, TotalStatsPeriod, total, ctx); err != nil {
+			utils.Logger.Error("Error while flushing total visitors", zap.String("linkID", linkId), zap.Error(err))
+			flushErr = err
+			c.requeue(linkId, TotalStatsPeriod, total)
+		}
+	}
+
+	return flushErr
+}
+
+// requeue keeps a sketch for the next flush. Merging a sketch twice doesn't change it,
+// so retrying a partially failed flush is safe.
+func (c *VisitorCounter) requeue(linkId string, period string, sketch *hll.Sketch) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pendingSketch(linkId, period).Merge(sketch)
+}
+
+func (c *VisitorCounter) pendingSketch(linkId string, period string) *hll.Sketch {
+	periods, ok := c.pending[linkId]
+	if !ok {
+		periods = make(map[string]*hll.Sketch)
+		c.pending[linkId] = periods
+	}
+
+	sketch, ok := periods[period]
+	if !ok {
+		sketch = hll.New()
+		periods[period] = sketch
+	}
+
+	return sketch
+}
+
+// Close stops the background flushing and writes the remaining sketches.
+func (c *VisitorCounter) Close(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+	})
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return c.Flush(ctx)
+}
+
+func (c *VisitorCounter) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush(context.Background())
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func NewVisitorCounter(repo LinkStatsRepository, interval time.Duration) *VisitorCounter {
+	counter := &VisitorCounter{
+		repo:     repo,
+		interval: interval,
+		pending:  make(map[string]map[string]*hll.Sketch),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go counter.run()
+
+	return counter
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/the-redx/link-shortener/internal/domain"
+	"github.com/the-redx/link-shortener/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// Membership changes are retried this many times when the workspace changes concurrently
+const memberChangeAttempts = 5
+
+var errLastWorkspaceOwner = errors.New("workspace must keep an owner")
+
+type WorkspaceService struct {
+	repo   WorkspaceRepository
+	users  UserRepository
+	access *Authorizer
+}
+
+// CreateWorkspace makes the user the owner of the new workspace.
+func (s *WorkspaceService) CreateWorkspace(workspaceDTO *domain.CreateWorkspaceDTO, ctx context.Context) (*domain.UserWorkspace, *errs.AppError) {
+	userId := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	workspace := domain.Workspace{
+		ID:          xid.New().String(),
+		Name:        workspaceDTO.Name,
+		DateCreated: time.Now(),
+	}
+
+	if err := s.repo.Put(&workspace, ctx); err != nil {
+		logger.Debug("Error while creating the workspace", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while creating workspace")
+	}
+
+	owner := domain.WorkspaceMember{
+		WorkspaceId: workspace.ID,
+		UserId:      userId,
+		Role:        domain.RoleOwner,
+		DateCreated: time.Now(),
+	}
+
+	if err := s.repo.PutMember(&owner, workspace.Version, ctx); err != nil {
+		logger.Debug("Error while adding the workspace owner", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while creating workspace")
+	}
+
+	logger.Debug("Workspace created", zap.Any("workspace", workspace))
+	return &domain.UserWorkspace{Workspace: workspace, Role: domain.RoleOwner}, nil
+}
+
+func (s *WorkspaceService) GetWorkspaces(ctx context.Context) ([]domain.UserWorkspace, *errs.AppError) {
+	userId := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	members, err := s.repo.ListByMember(userId, ctx)
+	if err != nil {
+		logger.Debug("Error while fetching workspaces", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while fetching workspaces")
+	}
+
+	workspaces := make([]domain.UserWorkspace, 0, len(members))
+	for _, member := range members {
+		workspace, err := s.repo.Get(member.WorkspaceId, ctx)
+		if err != nil {
+			logger.Debug("Error while fetching workspace", zap.String("workspaceID", member.WorkspaceId), zap.Error(err))
+			continue
+		}
+
+		workspaces = append(workspaces, domain.UserWorkspace{Workspace: *workspace, Role: member.Role})
+	}
+
+	return workspaces, nil
+}
+
+func (s *WorkspaceService) GetWorkspaceMembers(workspaceId string, ctx context.Context) ([]domain.WorkspaceMember, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if appErr := s.access.AuthorizeWorkspace(workspaceId, ActionViewMembers, ctx); appErr != nil {
+		return nil, appErr
+	}
+
+	members, err := s.repo.ListMembers(workspaceId, ctx)
+	if err != nil {
+		logger.Debug("Error while fetching workspace members", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while fetching workspace members")
+	}
+
+	return members, nil
+}
+
+// PutWorkspaceMember adds a user to the workspace or changes the role of a member.
+func (s *WorkspaceService) PutWorkspaceMember(workspaceId string, userId string, memberDTO *domain.PutWorkspaceMemberDTO, ctx context.Context) (*domain.WorkspaceMember, *errs.AppError) {
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if appErr := s.access.AuthorizeWorkspace(workspaceId, ActionManageMembers, ctx); appErr != nil {
+		return nil, appErr
+	}
+
+	if _, err := s.users.Get(userId, ctx); err != nil {
+		if err == ErrUserNotFound {
+			return nil, errs.NewNotFoundError("User not found")
+		}
+
+		logger.Debug("Error while fetching the user", zap.Error(err))
+		return nil, errs.NewUnexpectedError("Error while saving workspace member")
+	}
+
+	var member domain.WorkspaceMember
+	err := s.changeMember(workspaceId, userId, memberDTO.Role == domain.RoleOwner, func(current *domain.WorkspaceMember, version int) error {
+		member = domain.WorkspaceMember{
+			WorkspaceId: workspaceId,
+			UserId:      userId,
+			Role:        memberDTO.Role,
+			DateCreated: time.Now(),
+		}
+
+		// A role change keeps the date the user joined
+		if current != nil {
+			member.DateCreated = current.DateCreated
+		}
+
+		return s.repo.PutMember(&member, version, ctx)
+	}, ctx)
+
+	if err != nil {
+		return nil, workspaceMemberError(err, "Error while saving workspace member", logger)
+	}
+
+	logger.Debug("Workspace member saved", zap.Any("member", member))
+	return &member, nil
+}
+
+// DeleteWorkspaceMember removes a member. Members can always leave a workspace themselves.
+func (s *WorkspaceService) DeleteWorkspaceMember(workspaceId string, userId string, ctx context.Context) *errs.AppError {
+	currentUserId := ctx.Value("UserID").(string)
+	logger := ctx.Value("Logger").(*zap.SugaredLogger)
+
+	if userId != currentUserId {
+		if appErr := s.access.AuthorizeWorkspace(workspaceId, ActionManageMembers, ctx); appErr != nil {
+			return appErr
+		}
+	}
+
+	err := s.changeMember(workspaceId, userId, false, func(current *domain.WorkspaceMember, version int) error {
+		if current == nil {
+			return ErrWorkspaceMemberNotFound
+		}
+
+		return s.repo.DeleteMember(workspaceId, userId, version, ctx)
+	}, ctx)
+
+	if err != nil {
+		return workspaceMemberError(err, "Error while deleting workspace member", logger)
+	}
+
+	logger.Debugf("Workspace member %s deleted", userId)
+	return nil
+}
+
+// changeMember calls write with the current membership of the user, nil if the user isn't
+// a member, unless the change would take away the last owner of the workspace. The write
+// is made against the workspace version the owners were counted at, so two concurrent
+// changes can't both remove an owner. It is retried if another change got in between.
+func (s *WorkspaceService) changeMember(workspaceId string, userId string, keepsOwner bool, write func(current *domain.WorkspaceMember, version int) error, ctx context.Context) error {
+	for attempt := 0; attempt < memberChangeAttempts; attempt++ {
+		workspace, err := s.repo.Get(workspaceId, ctx)
+		if err != nil {
+			return err
+		}
+
+		members, err := s.repo.ListMembers(workspaceId, ctx)
+		if err != nil {
+			return err
+		}
+
+		var current *domain.WorkspaceMember
+		owners := 0
+		for i := range members {
+			if members[i].UserId == userId {
+				current = &members[i]
+			}
+
+			if members[i].Role == domain.RoleOwner {
+				owners++
+			}
+		}
+
+		if !keepsOwner && current != nil && current.Role == domain.RoleOwner && owners == 1 {
+			return errLastWorkspaceOwner
+		}
+
+		if err := write(current, workspace.Version); err != ErrWorkspaceChanged {
+			return err
+		}
+	}
+
+	return ErrWorkspaceChanged
+}
+
+func workspaceMemberError(err error, message string, logger *zap.SugaredLogger) *errs.AppError {
+	switch err {
+	case errLastWorkspaceOwner:
+		return errs.NewBadRequestError("A workspace must have at least one owner")
+	case ErrWorkspaceNotFound:
+		return errs.NewNotFoundError("Workspace not found")
+	case ErrWorkspaceMemberNotFound:
+		return errs.NewNotFoundError("Workspace member not found")
+	}
+
+	logger.Debug(message, zap.Error(err))
+	return errs.NewUnexpectedError(message)
+}
+
+func NewWorkspaceService(repo WorkspaceRepository, users UserRepository, access *Authorizer) WorkspaceService {
+	return WorkspaceService{repo: repo, users: users, access: access}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+var (
+	ErrWorkspaceNotFound       = errors.New("workspace not found")
+	ErrWorkspaceMemberNotFound = errors.New("workspace member not found")
+	ErrWorkspaceChanged        = errors.New("workspace members changed concurrently")
+)
+
+type WorkspaceRepository interface {
+	Get(id string, ctx context.Context) (*domain.Workspace, error)
+	Put(workspace *domain.Workspace, ctx context.Context) error
+	// GetMember returns ErrWorkspaceMemberNotFound if the user isn't a member of the workspace.
+	GetMember(workspaceId string, userId string, ctx context.Context) (*domain.WorkspaceMember, error)
+	// PutMember adds the member or replaces its role and bumps the workspace version.
+	// It fails with ErrWorkspaceChanged if the workspace isn't at the given version.
+	PutMember(member *domain.WorkspaceMember, version int, ctx context.Context) error
+	// DeleteMember removes the member and bumps the workspace version, like PutMember.
+	DeleteMember(workspaceId string, userId string, version int, ctx context.Context) error
+	// Get and ListMembers read the latest writes, so the members match the version of the workspace read before them.
+	ListMembers(workspaceId string, ctx context.Context) ([]domain.WorkspaceMember, error)
+	// ListByMember returns the memberships of the user.
+	ListByMember(userId string, ctx context.Context) ([]domain.WorkspaceMember, error)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+
+	"github.com/guregu/dynamo/v2"
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+var workspaceMembersByUserIndex = dynamo.Index{
+	Name:           "UserId-WorkspaceId-index",
+	HashKey:        "UserId",
+	HashKeyType:    dynamo.StringType,
+	RangeKey:       "WorkspaceId",
+	RangeKeyType:   dynamo.StringType,
+	ProjectionType: dynamo.AllProjection,
+}
+
+type DynamoDBWorkspaceRepository struct {
+	db              *dynamo.DB
+	workspacesTable dynamo.Table
+	membersTable    dynamo.Table
+}
+
+func (r *DynamoDBWorkspaceRepository) Get(id string, ctx context.Context) (*domain.Workspace, error) {
+	var workspace domain.Workspace
+
+	if err := r.workspacesTable.Get("ID", id).Consistent(true).One(ctx, &workspace); err != nil {
+		if err == dynamo.ErrNotFound {
+			return nil, ErrWorkspaceNotFound
+		}
+
+		return nil, err
+	}
+
+	return &workspace, nil
+}
+
+func (r *DynamoDBWorkspaceRepository) Put(workspace *domain.Workspace, ctx context.Context) error {
+	return r.workspacesTable.Put(workspace).Run(ctx)
+}
+
+func (r *DynamoDBWorkspaceRepository) GetMember(workspaceId string, userId string, ctx context.Context) (*domain.WorkspaceMember, error) {
+	var member domain.WorkspaceMember
+
+	if err := r.membersTable.Get("WorkspaceId", workspaceId).Range("UserId", dynamo.Equal, userId).One(ctx, &member); err != nil {
+		if err == dynamo.ErrNotFound {
+			return nil, ErrWorkspaceMemberNotFound
+		}
+
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (r *DynamoDBWorkspaceRepository) PutMember(member *domain.WorkspaceMember, version int, ctx context.Context) error {
+	tx := r.db.WriteTx().
+		Put(r.membersTable.Put(member)).
+		Update(r.bumpVersion(member.WorkspaceId, version))
+
+	return workspaceTxError(tx.Run(ctx))
+}
+
+func (r *DynamoDBWorkspaceRepository) DeleteMember(workspaceId string, userId string, version int, ctx context.Context) error {
+	tx := r.db.WriteTx().
+		Delete(r.membersTable.Delete("WorkspaceId", workspaceId).Range("UserId", userId).If("attribute_exists('UserId')")).
+		Update(r.bumpVersion(workspaceId, version))
+
+	return workspaceTxError(tx.Run(ctx))
+}
+
+// bumpVersion updates the workspace version if it is still the given one.
+// Workspaces created before versioning have no version and count as version 0.
+func (r *DynamoDBWorkspaceRepository) bumpVersion(workspaceId string, version int) *dynamo.Update {
+	update := r.workspacesTable.Update("ID", workspaceId).Set("Version", version+1)
+	if version == 0 {
+		return update.If("attribute_exists('ID') AND (attribute_not_exists('Version') OR 'Version' = ?)", version)
+	}
+
+	return update.If("'Version' = ?", version)
+}
+
+// A failed condition means the workspace or the member changed since they were read.
+// The caller reads them again and finds out which.
+func workspaceTxError(err error) error {
+	if dynamo.IsCondCheckFailed(err) {
+		return ErrWorkspaceChanged
+	}
+
+	return err
+}
+
+func (r *DynamoDBWorkspaceRepository) ListMembers(workspaceId string, ctx context.Context) ([]domain.WorkspaceMember, error) {
+	var members []domain.WorkspaceMember
+
+	if err := r.membersTable.Get("WorkspaceId", workspaceId).Consistent(true).All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func (r *DynamoDBWorkspaceRepository) ListByMember(userId string, ctx context.Context) ([]domain.WorkspaceMember, error) {
+	var members []domain.WorkspaceMember
+
+	if err := r.membersTable.Get("UserId", userId).Index(workspaceMembersByUserIndex.Name).All(ctx, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func NewDynamoDBWorkspaceRepository(db *dynamo.DB) *DynamoDBWorkspaceRepository {
+	workspacesTable := GetOrCreateTable(db, "Workspaces", domain.Workspace{})
+	membersTable := GetOrCreateTable(db, "WorkspaceMembers", domain.WorkspaceMember{}, workspaceMembersByUserIndex)
+
+	return &DynamoDBWorkspaceRepository{db: db, workspacesTable: workspacesTable, membersTable: membersTable}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+)
+
+// MemoryWorkspaceRepository keeps workspaces in process memory.
+type MemoryWorkspaceRepository struct {
+	mu         sync.RWMutex
+	workspaces map[string]domain.Workspace
+	members    map[string]map[string]domain.WorkspaceMember
+}
+
+func (r *MemoryWorkspaceRepository) Get(id string, ctx context.Context) (*domain.Workspace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	workspace, ok := r.workspaces[id]
+	if !ok {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	return &workspace, nil
+}
+
+func (r *MemoryWorkspaceRepository) Put(workspace *domain.Workspace, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.workspaces[workspace.ID] = *workspace
+	return nil
+}
+
+func (r *MemoryWorkspaceRepository) GetMember(workspaceId string, userId string, ctx context.Context) (*domain.WorkspaceMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	member, ok := r.members[workspaceId][userId]
+	if !ok {
+		return nil, ErrWorkspaceMemberNotFound
+	}
+
+	return &member, nil
+}
+
+func (r *MemoryWorkspaceRepository) PutMember(member *domain.WorkspaceMember, version int, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.bumpVersion(member.WorkspaceId, version); err != nil {
+		return err
+	}
+
+	members, ok := r.members[member.WorkspaceId]
+	if !ok {
+		members = make(map[string]domain.WorkspaceMember)
+		r.members[member.WorkspaceId] = members
+	}
+
+	members[member.UserId] = *member
+	return nil
+}
+
+func (r *MemoryWorkspaceRepository) DeleteMember(workspaceId string, userId string, version int, ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.members[workspaceId][userId]; !ok {
+		return ErrWorkspaceMemberNotFound
+	}
+
+	if err := r.bumpVersion(workspaceId, version); err != nil {
+		return err
+	}
+
+	delete(r.members[workspaceId], userId)
+	return nil
+}
+
+// bumpVersion must be called with the lock held.
+func (r *MemoryWorkspaceRepository) bumpVersion(workspaceId string, version int) error {
+	workspace, ok := r.workspaces[workspaceId]
+	if !ok || workspace.Version != version {
+		return ErrWorkspaceChanged
+	}
+
+	workspace.Version++
+	r.workspaces[workspaceId] = workspace
+	return nil
+}
+
+func (r *MemoryWorkspaceRepository) ListMembers(workspaceId string, ctx context.Context) ([]domain.WorkspaceMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []domain.WorkspaceMember
+	for _, member := range r.members[workspaceId] {
+		members = append(members, member)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserId < members[j].UserId
+	})
+
+	return members, nil
+}
+
+func (r *MemoryWorkspaceRepository) ListByMember(userId string, ctx context.Context) ([]domain.WorkspaceMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []domain.WorkspaceMember
+	for _, workspaceMembers := range r.members {
+		if member, ok := workspaceMembers[userId]; ok {
+			members = append(members, member)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].WorkspaceId < members[j].WorkspaceId
+	})
+
+	return members, nil
+}
+
+func NewMemoryWorkspaceRepository() *MemoryWorkspaceRepository {
+	return &MemoryWorkspaceRepository{
+		workspaces: make(map[string]domain.Workspace),
+		members:    make(map[string]map[string]domain.WorkspaceMember),
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/the-redx/link-shortener/internal/domain"
+	"go.uber.org/zap"
+)
+
+func workspaceTestContext(userId string) context.Context {
+	ctx := context.WithValue(context.Background(), "UserID", userId)
+	return context.WithValue(ctx, "Logger", zap.NewNop().Sugar())
+}
+
+func TestWorkspaceKeepsLastOwner(t *testing.T) {
+	users := NewMemoryUserRepository()
+	for _, id := range []string{"alice", "bob"} {
+		if err := users.Put(&domain.User{ID: id}, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		change func(s *WorkspaceService, workspaceId string, userId string, ctx context.Context)
+	}{
+		{
+			name: "demote",
+			change: func(s *WorkspaceService, workspaceId string, userId string, ctx context.Context) {
+				s.PutWorkspaceMember(workspaceId, userId, &domain.PutWorkspaceMemberDTO{Role: domain.RoleEditor}, ctx)
+			},
+		},
+		{
+			name: "remove",
+			change: func(s *WorkspaceService, workspaceId string, userId string, ctx context.Context) {
+				s.DeleteWorkspaceMember(workspaceId, userId, ctx)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &racingWorkspaceRepository{MemoryWorkspaceRepository: NewMemoryWorkspaceRepository()}
+			service := NewWorkspaceService(repo, users, NewAuthorizer(repo))
+
+			workspace, appErr := service.CreateWorkspace(&domain.CreateWorkspaceDTO{Name: "test"}, workspaceTestContext("alice"))
+			if appErr != nil {
+				t.Fatal(appErr.ErrorMessage)
+			}
+
+			if _, appErr := service.PutWorkspaceMember(workspace.ID, "bob", &domain.PutWorkspaceMemberDTO{Role: domain.RoleOwner}, workspaceTestContext("alice")); appErr != nil {
+				t.Fatal(appErr.ErrorMessage)
+			}
+
+			// Both owners count two owners before either of them writes
+			repo.readers.Add(2)
+			repo.waiting.Store(2)
+
+			var wg sync.WaitGroup
+			for _, pair := range [][2]string{{"alice", "bob"}, {"bob", "alice"}} {
+				wg.Add(1)
+				go func(currentUserId string, userId string) {
+					defer wg.Done()
+					tt.change(&service, workspace.ID, userId, workspaceTestContext(currentUserId))
+				}(pair[0], pair[1])
+			}
+			wg.Wait()
+
+			members, _ := repo.ListMembers(workspace.ID, context.Background())
+			owners := 0
+			for _, member := range members {
+				if member.Role == domain.RoleOwner {
+					owners++
+				}
+			}
+
+			if owners != 1 {
+				t.Errorf("workspace has %d owners, want 1", owners)
+			}
+		})
+	}
+}
+
+// racingWorkspaceRepository holds the next readers of the members until all of them have read.
+type racingWorkspaceRepository struct {
+	*MemoryWorkspaceRepository
+	readers sync.WaitGroup
+	waiting atomic.Int32
+}
+
+func (r *racingWorkspaceRepository) ListMembers(workspaceId string, ctx context.Context) ([]domain.WorkspaceMember, error) {
+	members, err := r.MemoryWorkspaceRepository.ListMembers(workspaceId, ctx)
+
+	if r.waiting.Add(-1) >= 0 {
+		r.readers.Done()
+		r.readers.Wait()
+	}
+
+	return members, err
+}
@@ -19,6 +19,26 @@ func NewBadRequestError(message string) *AppError {
 	return &AppError{http.StatusBadRequest, message}
 }
 
+func NewUnauthorizedError(message string) *AppError {
+	return &AppError{http.StatusUnauthorized, message}
+}
+
 func NewForbiddenError(message string) *AppError {
 	return &AppError{http.StatusForbidden, message}
 }
+
+func NewGoneError(message string) *AppError {
+	return &AppError{http.StatusGone, message}
+}
+
+func NewTooManyRequestsError(message string) *AppError {
+	return &AppError{http.StatusTooManyRequests, message}
+}
+
+func NewPayloadTooLargeError(message string) *AppError {
+	return &AppError{http.StatusRequestEntityTooLarge, message}
+}
+
+func NewUnsupportedMediaTypeError(message string) *AppError {
+	return &AppError{http.StatusUnsupportedMediaType, message}
+}
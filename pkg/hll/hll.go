@@ -0,0 +1,133 @@
+// Package hll implements a HyperLogLog sketch for estimating the number of
+// distinct items without keeping the items themselves.
+package hll
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	precision = 12
+	registers = 1 << precision
+
+	denseEncoding  byte = 1
+	sparseEncoding byte = 2
+)
+
+var ErrInvalidSketch = errors.New("hll: invalid sketch data")
+
+// Sketch uses 4096 registers, which gives about 1.6% standard error.
+type Sketch struct {
+	registers [registers]uint8
+}
+
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// Add registers an item by its 64-bit hash. The hash must be uniformly distributed.
+func (s *Sketch) Add(hash uint64) {
+	index := hash >> (64 - precision)
+	// The guard bit keeps rank within the remaining 52 bits
+	rank := uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1))) + 1
+
+	if rank > s.registers[index] {
+		s.registers[index] = rank
+	}
+}
+
+// Merge adds every item of other to the sketch.
+func (s *Sketch) Merge(other *Sketch) {
+	for i, rank := range other.registers {
+		if rank > s.registers[i] {
+			s.registers[i] = rank
+		}
+	}
+}
+
+// Estimate returns the approximate number of distinct items added to the sketch.
+func (s *Sketch) Estimate() uint64 {
+	sum := 0.0
+	zeros := 0
+
+	for _, rank := range s.registers {
+		sum += 1 / float64(uint64(1)<<rank)
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	m := float64(registers)
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more precise for small cardinalities
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+// MarshalBinary stores only the non-empty registers while that is shorter than the full array.
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	used := 0
+	for _, rank := range s.registers {
+		if rank != 0 {
+			used++
+		}
+	}
+
+	if used*3 >= registers {
+		return append([]byte{denseEncoding}, s.registers[:]...), nil
+	}
+
+	data := make([]byte, 1, 1+used*3)
+	data[0] = sparseEncoding
+
+	for i, rank := range s.registers {
+		if rank != 0 {
+			data = binary.BigEndian.AppendUint16(data, uint16(i))
+			data = append(data, rank)
+		}
+	}
+
+	return data, nil
+}
+
+func (s *Sketch) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return ErrInvalidSketch
+	}
+
+	*s = Sketch{}
+
+	switch data[0] {
+	case denseEncoding:
+		if len(data) != 1+registers {
+			return ErrInvalidSketch
+		}
+
+		copy(s.registers[:], data[1:])
+	case sparseEncoding:
+		if (len(data)-1)%3 != 0 {
+			return ErrInvalidSketch
+		}
+
+		for i := 1; i < len(data); i += 3 {
+			index := binary.BigEndian.Uint16(data[i:])
+			if int(index) >= registers {
+				return ErrInvalidSketch
+			}
+
+			s.registers[index] = data[i+2]
+		}
+	default:
+		return ErrInvalidSketch
+	}
+
+	return nil
+}
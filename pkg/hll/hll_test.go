@@ -0,0 +1,119 @@
+package hll
+
+import (
+	"math"
+	"testing"
+)
+
+// hashOf spreads consecutive numbers uniformly with the SplitMix64 finalizer
+func hashOf(i int) uint64 {
+	x := uint64(i) + 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+
+	return x ^ x>>31
+}
+
+func sketchOf(from int, to int) *Sketch {
+	s := New()
+	for i := from; i < to; i++ {
+		s.Add(hashOf(i))
+	}
+
+	return s
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+	}{
+		{name: "empty", distinct: 0},
+		{name: "one", distinct: 1},
+		{name: "hundred", distinct: 100},
+		{name: "thousand", distinct: 1000},
+		{name: "ten thousand", distinct: 10000},
+		{name: "million", distinct: 1000000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sketchOf(0, tt.distinct)
+			// Adding the items again doesn't change the estimate
+			s.Merge(sketchOf(0, tt.distinct))
+
+			got := float64(s.Estimate())
+			// Five standard errors, with one item of slack for the small counts
+			if math.Abs(got-float64(tt.distinct)) > float64(tt.distinct)*0.08+1 {
+				t.Errorf("Estimate() = %.0f, want about %d", got, tt.distinct)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	merged := sketchOf(0, 5000)
+	merged.Merge(sketchOf(2500, 7500))
+
+	if got, want := merged.Estimate(), sketchOf(0, 7500).Estimate(); got != want {
+		t.Errorf("Estimate() of merged sketches = %d, want %d like one sketch of all items", got, want)
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+		encoding byte
+	}{
+		{name: "empty", distinct: 0, encoding: sparseEncoding},
+		{name: "sparse", distinct: 100, encoding: sparseEncoding},
+		{name: "dense", distinct: 100000, encoding: denseEncoding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := sketchOf(0, tt.distinct)
+
+			data, err := s.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() error = %v", err)
+			}
+
+			if data[0] != tt.encoding {
+				t.Errorf("MarshalBinary() encoding = %d, want %d", data[0], tt.encoding)
+			}
+
+			var got Sketch
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary() error = %v", err)
+			}
+
+			if got != *s {
+				t.Errorf("UnmarshalBinary() doesn't restore the registers")
+			}
+		})
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "unknown encoding", data: []byte{9}},
+		{name: "short dense", data: []byte{denseEncoding, 1, 2}},
+		{name: "truncated sparse", data: []byte{sparseEncoding, 0, 1}},
+		{name: "sparse index out of range", data: []byte{sparseEncoding, 0x10, 0x00, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Sketch
+			if err := s.UnmarshalBinary(tt.data); err != ErrInvalidSketch {
+				t.Errorf("UnmarshalBinary(%v) error = %v, want ErrInvalidSketch", tt.data, err)
+			}
+		})
+	}
+}
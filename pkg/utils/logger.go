@@ -1,8 +1,6 @@
 package utils
 
 import (
-	"os"
-
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -17,10 +15,7 @@ func getLevelLogger(level string) zapcore.Level {
 	return zap.ErrorLevel
 }
 
-func InitLogger() {
-	appLogLevel := os.Getenv("APP_LOG_LEVEL")
-	appEnv := os.Getenv("APP_ENV")
-
+func InitLogger(appLogLevel string, appEnv string) {
 	zapConfig := zap.NewProductionConfig()
 	zapConfig.EncoderConfig = zap.NewProductionEncoderConfig()
 	zapConfig.Level = zap.NewAtomicLevelAt(getLevelLogger(appLogLevel))
@@ -0,0 +1,64 @@
+package utils
+
+import "strings"
+
+type UserAgent struct {
+	Browser string
+	Device  string
+}
+
+// Order matters: most browsers also mention the engines of the ones below them
+var browserTokens = []struct {
+	name   string
+	tokens []string
+}{
+	{"Edge", []string{"Edg/", "EdgA/", "EdgiOS/", "Edge/"}},
+	{"Opera", []string{"OPR/", "Opera"}},
+	{"Samsung Internet", []string{"SamsungBrowser/"}},
+	{"Yandex", []string{"YaBrowser/"}},
+	{"Chrome", []string{"Chrome/", "CriOS/"}},
+	{"Firefox", []string{"Firefox/", "FxiOS/"}},
+	{"Safari", []string{"Safari/"}},
+	{"Internet Explorer", []string{"MSIE ", "Trident/"}},
+}
+
+// ParseUserAgent detects the browser family and the device class of a User-Agent header.
+// It only looks for well-known tokens and falls back to "Other" and "unknown".
+func ParseUserAgent(userAgent string) UserAgent {
+	ua := UserAgent{Browser: "Other", Device: "unknown"}
+
+	if userAgent == "" {
+		return ua
+	}
+
+	for _, browser := range browserTokens {
+		if containsAny(userAgent, browser.tokens) {
+			ua.Browser = browser.name
+			break
+		}
+	}
+
+	lower := strings.ToLower(userAgent)
+
+	switch {
+	case containsAny(lower, []string{"ipad", "tablet", "kindle", "silk/"}),
+		strings.Contains(lower, "android") && !strings.Contains(lower, "mobile"):
+		ua.Device = "tablet"
+	case containsAny(lower, []string{"mobi", "iphone", "ipod", "android", "windows phone"}):
+		ua.Device = "mobile"
+	case containsAny(lower, []string{"windows", "macintosh", "x11", "linux", "cros"}):
+		ua.Device = "desktop"
+	}
+
+	return ua
+}
+
+func containsAny(value string, tokens []string) bool {
+	for _, token := range tokens {
+		if strings.Contains(value, token) {
+			return true
+		}
+	}
+
+	return false
+}